@@ -8,9 +8,12 @@ import (
 )
 
 const (
-	flagFeedURL = "feed-url"
-	flagName    = "name"
-	flagStorage = "storage"
+	flagFeedURL          = "feed-url"
+	flagName             = "name"
+	flagStorage          = "storage"
+	flagFilenameTemplate = "filename-template"
+	flagTagFiles         = "tag-files"
+	flagEpisodeArtwork   = "episode-artwork"
 )
 
 var addCmd = &cobra.Command{
@@ -19,7 +22,24 @@ var addCmd = &cobra.Command{
 	Long: `Adds a podcast to your list of managed podcasts.
 Providing a name, feed URL, and storage location for episodes.
 Unless a podcast by that name is already managed, this initializes the storage location and
-downloads the newest feed.`,
+downloads the newest feed.
+
+Episode file names are derived from a template, e.g.
+  {{.PubDate "2006-01-02"}} - {{.Season}}x{{.Number}} {{.Title}}{{.Ext}}
+Available are .Title, .GUID, .Season, .Number, .Podcast, .Ext and .PubDate <layout>.
+Without a template the global one from $` + envFilenameTemplate + ` is used, or
+else the filename_template of the global settings. The environment variable
+takes precedence over the setting.
+
+Without --storage the podcast is stored in a directory named after it in the
+library_root of the global settings. Relative storage paths are resolved against
+the current directory.
+
+With --tag-files the title, podcast, date, episode and season number, description
+and cover art from the feed are written into downloaded MP3 and M4A files.
+
+The podcast cover art is saved as cover.jpg and folder.jpg in the storage location.
+With --episode-artwork episode images are saved next to the episodes as well.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if err := cmd.Flags().Parse(args); err != nil {
 			return err
@@ -28,17 +48,36 @@ downloads the newest feed.`,
 		name := cmd.Flag(flagName).Value.String()
 		feedURL := cmd.Flag(flagFeedURL).Value.String()
 		storage := cmd.Flag(flagStorage).Value.String()
+		filenameTmpl := cmd.Flag(flagFilenameTemplate).Value.String()
+		tagFiles, _ := cmd.Flags().GetBool(flagTagFiles)
+		episodeArtwork, _ := cmd.Flags().GetBool(flagEpisodeArtwork)
 
-		return add(name, feedURL, storage)
+		return add(name, feedURL, storage, filenameTmpl, tagFiles, episodeArtwork)
 	},
 }
 
-func add(name, feedURL, storage string) error {
-	podcast, err := pod.New(name, feedURL, storage)
+func add(name, feedURL, storage, filenameTmpl string, tagFiles, episodeArtwork bool) error {
+	podcast, err := library.New(name, feedURL, storage, filenameTmpl)
 	if err != nil {
 		return err
 	}
 
+	if tagFiles || episodeArtwork {
+		if tagFiles {
+			podcast.Settings.TagFiles = &tagFiles
+		}
+
+		if episodeArtwork {
+			podcast.Settings.EpisodeArtwork = &episodeArtwork
+		}
+
+		if err := podcast.Save(); err != nil {
+			return err
+		}
+	}
+
+	updateArtwork([]*pod.Podcast{podcast})
+
 	log.Printf("podcast %s added under %s", podcast.Name, podcast.LocalStore)
 
 	return nil
@@ -47,8 +86,10 @@ func add(name, feedURL, storage string) error {
 func init() {
 	addCmd.Flags().StringP("feed-url", "u", "", "URL of the podcast feed")
 	addCmd.Flags().StringP("name", "n", "", "Name under which the podcast should be managed")
-	addCmd.Flags().StringP("storage", "s", "", "Path to directory (absolute) where to store episodes")
+	addCmd.Flags().StringP("storage", "s", "", "Path to directory where to store episodes (default <library_root>/<name>)")
+	addCmd.Flags().StringP("filename-template", "t", "", "Template for episode file names")
+	addCmd.Flags().Bool(flagTagFiles, false, "Write feed metadata into downloaded episode files")
+	addCmd.Flags().Bool(flagEpisodeArtwork, false, "Save episode images next to the episode files")
 	_ = addCmd.MarkFlagRequired("feed-url")
 	_ = addCmd.MarkFlagRequired("name")
-	_ = addCmd.MarkFlagRequired("storage")
 }
@@ -4,8 +4,31 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+
+	"github.com/jtepe/gopodgrab/pod"
 )
 
+// podsFromArgs looks up the managed podcasts named by args. The
+// special name "all" selects all managed podcasts.
+func podsFromArgs(args []string) ([]*pod.Podcast, error) {
+	pods := make([]*pod.Podcast, 0, len(args))
+
+	for _, arg := range args {
+		if arg == pod.ReservedPodName {
+			return library.Podcasts(), nil
+		}
+
+		p, err := library.Get(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		pods = append(pods, p)
+	}
+
+	return pods, nil
+}
+
 // waitApproval blocks until the user approves or denies the progression
 // with a "y" or "yes" input.Every other input (or error) is interpreted
 // as disapproval. The function adds a (yes/no) substring to the message,
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jtepe/gopodgrab/pod"
+	"github.com/spf13/cobra"
+)
+
+var convertTo string
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration file",
+	Long: `The configuration file holds the managed podcasts and the settings. It is
+either in JSON or TOML format, depending on its file extension. These commands
+show and change it.
+
+Settings of a podcast take precedence over the global ones. The global
+settings.filename_template is overridden by $` + envFilenameTemplate + ` if set.`,
+}
+
+var configConvertCmd = &cobra.Command{
+	Use: "convert [<file>]",
+	Example: `gopodgrab config convert --to toml
+gopodgrab config convert ~/podcasts.json`,
+	Short: "Convert the configuration file between JSON and TOML",
+	Long: `Converts the configuration file to the format given by --to. The converted
+file replaces the current one, which is kept as a backup with a .bak suffix.
+
+Given a file, the configuration is written to it instead, in the format of its
+extension, and the current configuration file is left in place. Comments of a
+TOML file are kept when converting to TOML again.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{annotationNoLoad: ""},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cf, _, err := library.ConfigInfo()
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 1 {
+			if err := library.ConvertConfig(args[0], false); err != nil {
+				return err
+			}
+
+			fmt.Printf("Configuration %s written to %s\n", cf, args[0])
+
+			return nil
+		}
+
+		ext := "." + strings.ToLower(convertTo)
+		if ext != ".toml" && ext != ".json" {
+			return fmt.Errorf("unknown format %q, use toml or json", convertTo)
+		}
+
+		if strings.EqualFold(filepath.Ext(cf), ext) {
+			fmt.Printf("Configuration %s is in %s format already.\n", cf, convertTo)
+			return nil
+		}
+
+		dst := strings.TrimSuffix(cf, filepath.Ext(cf)) + ext
+
+		msg := fmt.Sprintf("Convert %s to %s, keeping it as %s.bak?", cf, dst, cf)
+		if !waitApproval(msg) {
+			return nil
+		}
+
+		if err := library.ConvertConfig(dst, true); err != nil {
+			return err
+		}
+
+		fmt.Printf("Configuration converted to %s\n", dst)
+
+		if pod.ConfigFile != "" {
+			fmt.Printf("Select it with --config %s from now on.\n", dst)
+		}
+
+		return nil
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use: "get <key>",
+	Example: `gopodgrab config get settings.reserve
+gopodgrab config get podcasts.foocast.storage`,
+	Short: "Show a configuration value",
+	Long: `Shows the value of a configuration key. Keys are dotted paths into the
+configuration file, like settings.reserve, podcasts.foocast.storage or
+podcasts.foocast.settings.max_episodes. Tables are shown as JSON.`,
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{annotationNoLoad: ""},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		v, err := library.GetConfig(args[0])
+		if err != nil {
+			return err
+		}
+
+		if s, ok := v.(string); ok {
+			fmt.Println(s)
+			return nil
+		}
+
+		buf, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(buf))
+
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use: "set <key> <value>",
+	Example: `gopodgrab config set settings.reserve 5GB
+gopodgrab config set podcasts.foocast.settings.max_episodes 3
+gopodgrab config set podcasts.foocast.settings.retention.pinned '["foo-1"]'`,
+	Short: "Change a configuration value",
+	Long: `Sets a configuration key, see config get. Values of text keys are taken as
+given, other values are JSON, like 3, true or ["foo-1"]. The configuration is
+checked before it is saved, invalid changes are rejected.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return library.SetConfig(args[0], args[1])
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:     "unset <key>",
+	Example: "gopodgrab config unset podcasts.foocast.settings.download_order",
+	Short:   "Remove a configuration value",
+	Long: `Removes the value of a configuration key, see config get. Podcast settings
+fall back to the global ones again.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return library.UnsetConfig(args[0])
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the configuration file",
+	Long: `Opens a copy of the configuration file in $VISUAL or $EDITOR. The copy
+replaces the configuration once it is checked to be valid, otherwise it can be
+edited again.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{annotationNoLoad: ""},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		retry := func(err error) bool {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+			return waitApproval("Edit again?")
+		}
+
+		changed, err := library.EditConfig(runEditor, retry)
+		if err != nil {
+			return err
+		}
+
+		if !changed {
+			fmt.Println("Configuration unchanged.")
+		}
+
+		return nil
+	},
+}
+
+// runEditor opens the file at path in the user's editor and waits for
+// it to exit.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	// The editor may come with arguments, like "code --wait".
+	args := append(strings.Fields(editor), path)
+
+	c := exec.Command(args[0], args[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	return c.Run()
+}
+
+func init() {
+	configConvertCmd.Flags().StringVar(&convertTo, "to", "toml", "Format to convert to: toml or json")
+
+	configCmd.AddCommand(configGetCmd, configSetCmd, configUnsetCmd, configEditCmd, configConvertCmd)
+}
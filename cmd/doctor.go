@@ -12,14 +12,29 @@ import (
 var doctorCmd = &cobra.Command{
 	Use:   "doctor",
 	Short: "Managed podcast maintenance",
-	Long:  `Checks all managed podcasts for broken storage or missing feed files, suggesting actions where possible.`,
+	Long: `Checks all managed podcasts for broken storage or missing feed files, suggesting actions where possible.
+Also shows the location and format version of the configuration file.`,
+	// The library is loaded only once the version of the file is known,
+	// as loading migrates it to the current one.
+	Annotations: map[string]string{annotationNoLoad: ""},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		pods, err := pod.List()
+		cf, version, err := library.ConfigInfo()
 		if err != nil {
 			return err
 		}
 
-		return checkStorage(pods)
+		fmt.Printf("Configuration file %s, format version %d\n", cf, version)
+
+		if version < pod.ConfigVersion {
+			fmt.Printf("... outdated, it is migrated to version %d now, the original is kept as %s.v%d.bak\n",
+				pod.ConfigVersion, cf, version)
+		}
+
+		if err := library.Reload(); err != nil {
+			return err
+		}
+
+		return checkStorage(library.Podcasts())
 	},
 }
 
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jtepe/gopodgrab/pod"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	flagPaused        = "paused"
+	flagAutoApprove   = "auto-approve"
+	flagDownloadOrder = "download-order"
+	flagMaxEpisodes   = "max-episodes"
+	flagEnclosureType = "enclosure-type"
+	flagCategory      = "category"
+)
+
+var editCmd = &cobra.Command{
+	Use: "edit <podcast>",
+	Example: `gopodgrab edit foocast --name foo
+gopodgrab edit foocast --feed-url https://example.com/new-feed.xml --max-episodes 3`,
+	Short: "Change the name, feed URL, storage or settings of a podcast",
+	Long: `Changes the podcast by the flags given, leaving everything else as it is.
+
+A new name must not be taken by another podcast, the stored feed is renamed
+along with the podcast. A new feed URL must serve a valid feed, which replaces
+the stored one and is added to the feed history. A new storage directory is
+moved to as the move command does.
+
+Settings given by flags override the global ones for the podcast. To fall back
+to the global settings again, use config unset.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := library.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		var changes []string
+		cmd.Flags().Visit(func(f *pflag.Flag) {
+			changes = append(changes, f.Name+" "+f.Value.String())
+		})
+
+		if len(changes) == 0 {
+			return fmt.Errorf("nothing to change for %s, see --help for the flags", p.Name)
+		}
+
+		msg := fmt.Sprintf("Change %s: %s?", p.Name, strings.Join(changes, ", "))
+		if !waitApproval(msg) {
+			return nil
+		}
+
+		if err := library.Edit(p.Name, func(p *pod.Podcast) error { return editPod(cmd.Flags(), p) }); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s changed.\n", p.Name)
+
+		return nil
+	},
+}
+
+// editPod applies the flags set in flags to p.
+func editPod(flags *pflag.FlagSet, p *pod.Podcast) error {
+	s := &p.Settings
+	var err error
+
+	flags.Visit(func(f *pflag.Flag) {
+		if err != nil {
+			return
+		}
+
+		switch f.Name {
+		case flagName:
+			p.Name = f.Value.String()
+		case flagFeedURL:
+			p.FeedURL = f.Value.String()
+		case flagStorage:
+			p.LocalStore = f.Value.String()
+		case flagCategory:
+			p.Category = f.Value.String()
+		case flagFilenameTemplate:
+			s.FilenameTemplate = f.Value.String()
+		case flagDownloadOrder:
+			s.DownloadOrder = f.Value.String()
+		case flagEnclosureType:
+			s.EnclosureType = f.Value.String()
+		case flagMaxEpisodes:
+			var n int
+			n, err = flags.GetInt(f.Name)
+			s.MaxEpisodes = &n
+		case flagPaused, flagAutoApprove, flagTagFiles, flagEpisodeArtwork:
+			var b bool
+			b, err = flags.GetBool(f.Name)
+
+			switch f.Name {
+			case flagPaused:
+				s.Paused = &b
+			case flagAutoApprove:
+				s.AutoApprove = &b
+			case flagTagFiles:
+				s.TagFiles = &b
+			case flagEpisodeArtwork:
+				s.EpisodeArtwork = &b
+			}
+		}
+	})
+
+	return err
+}
+
+func init() {
+	editCmd.Flags().StringP(flagName, "n", "", "New name of the podcast")
+	editCmd.Flags().StringP(flagFeedURL, "u", "", "New URL of the podcast feed")
+	editCmd.Flags().StringP(flagStorage, "s", "", "New directory to store episodes in")
+	editCmd.Flags().String(flagCategory, "", "Category of the podcast, like News/Tech")
+	editCmd.Flags().StringP(flagFilenameTemplate, "t", "", "Template for episode file names")
+	editCmd.Flags().Bool(flagPaused, false, "Skip the podcast when updating")
+	editCmd.Flags().Bool(flagAutoApprove, false, "Download new episodes without asking")
+	editCmd.Flags().String(flagDownloadOrder, "", "Order to download new episodes in: feed, oldest or newest")
+	editCmd.Flags().Int(flagMaxEpisodes, 0, "Maximum number of new episodes downloaded per run, 0 for all")
+	editCmd.Flags().String(flagEnclosureType, "", "Preferred MIME type of episodes with several enclosures")
+	editCmd.Flags().Bool(flagTagFiles, false, "Write feed metadata into downloaded episode files")
+	editCmd.Flags().Bool(flagEpisodeArtwork, false, "Save episode images next to the episode files")
+}
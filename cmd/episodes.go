@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jtepe/gopodgrab/pod"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagStatus  = "status"
+	flagSince   = "since"
+	flagUntil   = "until"
+	flagTitle   = "title"
+	flagSort    = "sort"
+	flagReverse = "reverse"
+	flagLimit   = "limit"
+	flagPage    = "page"
+
+	dateLayout = "2006-01-02"
+)
+
+var episodesCmd = &cobra.Command{
+	Use: "episodes <podcast>",
+	Example: `gopodgrab episodes foocast --status new,missing
+gopodgrab episodes foocast --since 2021-01-01 --title '(?i)interview' --sort date --reverse --limit 10`,
+	Short: "List the episodes of a podcast's feed with their download status",
+	Long: `Lists every item of the stored feed of the specified podcast with its
+position in the feed, publishing date, duration, size and status. The status is
+one of:
+
+  downloaded  the episode file is in the storage directory
+  new         the episode is not downloaded yet, update would download it
+  ignored     the episode is not downloaded by update, as it was pruned, has
+              no enclosure or repeats an earlier item
+  missing     the episode was downloaded, but its file is gone
+
+Items are listed in feed order unless sorted by --sort, which takes index,
+date, title, duration, size or status. --since and --until limit the
+publishing dates to a range of days, both included. With --limit the list is
+split into pages of that many items, --page selects one.
+
+The feed is not refreshed, run update to fetch the latest one. Nothing in the
+storage directory is changed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := library.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		filter, err := itemFilter(cmd)
+		if err != nil {
+			return err
+		}
+
+		items, err := p.FeedItems()
+		if err != nil {
+			return err
+		}
+
+		items = filter.Filter(items)
+
+		sortKey, _ := cmd.Flags().GetString(flagSort)
+		reverse, _ := cmd.Flags().GetBool(flagReverse)
+
+		if err := pod.SortItems(items, sortKey, reverse); err != nil {
+			return err
+		}
+
+		limit, _ := cmd.Flags().GetInt(flagLimit)
+		page, _ := cmd.Flags().GetInt(flagPage)
+
+		if limit < 0 || page < 1 {
+			return fmt.Errorf("--%s must not be negative and --%s must be at least 1", flagLimit, flagPage)
+		}
+
+		total := len(items)
+		if limit > 0 {
+			from := (page - 1) * limit
+			if from > total {
+				from = total
+			}
+
+			to := from + limit
+			if to > total {
+				to = total
+			}
+
+			items = items[from:to]
+		}
+
+		if len(items) == 0 {
+			fmt.Printf("No episodes of %s match.\n", p.Name)
+			return nil
+		}
+
+		printItems(items)
+
+		if limit > 0 {
+			fmt.Printf("\nPage %d of %d, %d episodes.\n", page, (total+limit-1)/limit, total)
+		}
+
+		return nil
+	},
+}
+
+// itemFilter builds the filter of feed items from the flags of cmd.
+func itemFilter(cmd *cobra.Command) (*pod.ItemFilter, error) {
+	filter := &pod.ItemFilter{}
+
+	statuses, _ := cmd.Flags().GetStringSlice(flagStatus)
+	for _, s := range statuses {
+		status, err := pod.ParseEpisodeStatus(s)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	if since, _ := cmd.Flags().GetString(flagSince); since != "" {
+		t, err := time.ParseInLocation(dateLayout, since, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("--%s: %w", flagSince, err)
+		}
+
+		filter.Since = t
+	}
+
+	if until, _ := cmd.Flags().GetString(flagUntil); until != "" {
+		t, err := time.ParseInLocation(dateLayout, until, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("--%s: %w", flagUntil, err)
+		}
+
+		filter.Until = t.AddDate(0, 0, 1)
+	}
+
+	if title, _ := cmd.Flags().GetString(flagTitle); title != "" {
+		re, err := regexp.Compile(title)
+		if err != nil {
+			return nil, fmt.Errorf("--%s: %w", flagTitle, err)
+		}
+
+		filter.Title = re
+	}
+
+	return filter, nil
+}
+
+// printItems prints the feed items as a table.
+func printItems(items []*pod.FeedItem) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tDate\tDuration\tSize\tStatus\tTitle")
+
+	for _, it := range items {
+		date := "-"
+		if !it.Published.IsZero() {
+			date = it.Published.Local().Format(dateLayout)
+		}
+
+		duration := "-"
+		if d := it.Episode.Duration; d > 0 {
+			duration = fmt.Sprintf("%d:%02d:%02d", d/3600, d/60%60, d%60)
+		}
+
+		size := "-"
+		if it.Size > 0 {
+			size = humanized(it.Size)
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", it.Index, date, duration, size, it.Status, it.Episode.Title)
+	}
+	tw.Flush()
+}
+
+func init() {
+	episodesCmd.Flags().StringSlice(flagStatus, nil, "Only list episodes of these statuses: downloaded, new, ignored or missing")
+	episodesCmd.Flags().String(flagSince, "", "Only list episodes published on or after this day, like 2021-01-31")
+	episodesCmd.Flags().String(flagUntil, "", "Only list episodes published on or before this day, like 2021-12-31")
+	episodesCmd.Flags().String(flagTitle, "", "Only list episodes whose title matches this regular expression")
+	episodesCmd.Flags().String(flagSort, "index", "Sort by index, date, title, duration, size or status")
+	episodesCmd.Flags().Bool(flagReverse, false, "Reverse the sort order")
+	episodesCmd.Flags().Int(flagLimit, 0, "Number of episodes per page, 0 for all")
+	episodesCmd.Flags().Int(flagPage, 1, "Page of episodes to list with --limit")
+}
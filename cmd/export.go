@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagOutput = "output"
+	flagFlat   = "flat"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export podcasts for other podcatchers",
+}
+
+var exportOPMLCmd = &cobra.Command{
+	Use: "opml",
+	Example: `gopodgrab export opml > subscriptions.opml
+gopodgrab export opml --flat -o phone.opml`,
+	Short: "Export the managed podcasts as OPML",
+	Long: `Writes all managed podcasts as an OPML 2.0 document, which podcatchers and
+mobile apps can import, to standard output or the file given by --output.
+
+Podcasts are listed with the title of their feed, as of the last update, and
+grouped in outlines by their category. --flat lists them without grouping, for
+apps that don't support it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flat, _ := cmd.Flags().GetBool(flagFlat)
+		out := cmd.Flag(flagOutput).Value.String()
+
+		var buf bytes.Buffer
+		if err := library.WriteOPML(&buf, flat); err != nil {
+			return err
+		}
+
+		if out == "" || out == "-" {
+			_, err := buf.WriteTo(os.Stdout)
+			return err
+		}
+
+		return ioutil.WriteFile(out, buf.Bytes(), 0644)
+	},
+}
+
+func init() {
+	exportOPMLCmd.Flags().StringP(flagOutput, "o", "", "File to write to (default standard output)")
+	exportOPMLCmd.Flags().Bool(flagFlat, false, "List the podcasts without grouping them by category")
+
+	exportCmd.AddCommand(exportOPMLCmd)
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/jtepe/gopodgrab/pod"
+	"github.com/spf13/cobra"
+)
+
+var feedCmd = &cobra.Command{
+	Use:   "feed",
+	Short: "Inspect the feed history of a podcast",
+	Long: `Every feed refresh that changes the feed keeps a snapshot of it in the
+podcast's storage directory. These commands inspect the snapshots.`,
+}
+
+var feedHistoryCmd = &cobra.Command{
+	Use:     "history <podcast>",
+	Example: "gopodgrab feed history foocast",
+	Short:   "List the feed snapshots of a podcast",
+	Long: `Lists the feed snapshots of the specified podcast, oldest first, with
+the number of episodes in each. The numbers identify snapshots for feed diff.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := library.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		snaps, err := p.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		if len(snaps) == 0 {
+			fmt.Printf("No feed snapshots of %s yet.\n", p.Name)
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for i, s := range snaps {
+			eps, err := s.Episodes()
+			if err != nil {
+				fmt.Fprintf(tw, "%d\t%s\t%v\n", i+1, s.Time.Local().Format("2006-01-02 15:04:05"), err)
+				continue
+			}
+
+			fmt.Fprintf(tw, "%d\t%s\t%d episodes\n", i+1, s.Time.Local().Format("2006-01-02 15:04:05"), len(eps))
+		}
+		tw.Flush()
+
+		return nil
+	},
+}
+
+var feedDiffCmd = &cobra.Command{
+	Use:     "diff <podcast> [<from> [<to>]]",
+	Example: "gopodgrab feed diff foocast 3 7",
+	Short:   "Show what changed between two feed snapshots",
+	Long: `Shows the episodes added, removed and modified between two feed snapshots
+of the specified podcast, numbered as listed by feed history. Without numbers
+the two newest snapshots are compared, with one number that snapshot is
+compared to the newest.`,
+	Args: cobra.RangeArgs(1, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := library.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		snaps, err := p.Snapshots()
+		if err != nil {
+			return err
+		}
+
+		from, to := len(snaps)-1, len(snaps)
+
+		if len(args) > 1 {
+			if from, err = strconv.Atoi(args[1]); err != nil {
+				return err
+			}
+		}
+
+		if len(args) > 2 {
+			if to, err = strconv.Atoi(args[2]); err != nil {
+				return err
+			}
+		}
+
+		diff, err := p.DiffSnapshots(from, to)
+		if err != nil {
+			return err
+		}
+
+		printDiff(diff)
+
+		return nil
+	},
+}
+
+// printDiff prints the feed diff in a format similar to a unified diff.
+func printDiff(diff *pod.FeedDiff) {
+	if len(diff.Added)+len(diff.Removed)+len(diff.Modified) == 0 {
+		fmt.Println("No differences.")
+		return
+	}
+
+	for _, e := range diff.Added {
+		fmt.Printf("+ %s\n", e.Title)
+	}
+
+	for _, e := range diff.Removed {
+		fmt.Printf("- %s\n", e.Title)
+	}
+
+	for _, c := range diff.Modified {
+		fmt.Printf("~ %s\n", c.New.Title)
+		for _, change := range c.Changes {
+			fmt.Printf("    %s\n", change)
+		}
+	}
+}
+
+func init() {
+	feedCmd.AddCommand(feedHistoryCmd, feedDiffCmd)
+}
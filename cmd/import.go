@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jtepe/gopodgrab/pod"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagFetch       = "fetch"
+	flagJobs        = "jobs"
+	flagStorageRoot = "storage-root"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import podcasts from other podcatchers",
+}
+
+var importOPMLCmd = &cobra.Command{
+	Use:     "opml <file>",
+	Example: "gopodgrab import opml subscriptions.opml --fetch",
+	Short:   "Import podcasts from an OPML file",
+	Long: `Adds the podcasts listed in an OPML 1.0 or 2.0 file, as exported by most
+podcatchers. A file of - reads standard input. Outlines nested in category
+outlines are found as well, the category is recorded with the podcast.
+
+Names are derived from the podcast titles, and the podcasts are stored in
+directories named after them in --storage-root, or the library_root of the
+global settings. Podcasts whose feed is managed already are skipped.
+
+With --fetch the feeds are fetched right away, --jobs at a time, and podcasts
+whose feed cannot be fetched are not added. Otherwise the feeds are fetched by
+the next update. A table of the outcome for every podcast is printed last.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fetch, _ := cmd.Flags().GetBool(flagFetch)
+		jobs, _ := cmd.Flags().GetInt(flagJobs)
+		root := cmd.Flag(flagStorageRoot).Value.String()
+
+		if root == "" && library.Root() == "" {
+			return pod.ErrNoStorage
+		}
+
+		if !fetch {
+			jobs = 0
+		} else if jobs < 1 {
+			return errors.New("at least one job is needed to fetch feeds")
+		}
+
+		subs, err := readOPML(args[0])
+		if err != nil {
+			return err
+		}
+
+		if len(subs) == 0 {
+			fmt.Println("No podcasts found.")
+			return nil
+		}
+
+		printImport(library.Import(subs, root, jobs))
+
+		return nil
+	},
+}
+
+// readOPML reads the subscriptions from the OPML file at path, or from
+// stdin for "-".
+func readOPML(path string) ([]*pod.Subscription, error) {
+	var r io.Reader = os.Stdin
+
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	return pod.ParseOPML(r)
+}
+
+// printImport prints the outcome of an import as a table.
+func printImport(results []*pod.ImportResult) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TITLE\tNAME\tCATEGORY\tRESULT")
+
+	added := 0
+
+	for _, res := range results {
+		name, result := "-", ""
+
+		switch {
+		case res.Existing != nil:
+			name, result = res.Existing.Name, "skipped, managed already"
+		case res.Err != nil:
+			result = "failed: " + res.Err.Error()
+		default:
+			name, result = res.Podcast.Name, "added to "+res.Podcast.LocalStore
+			added++
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", res.Sub.Title, name, res.Sub.Category, result)
+	}
+
+	tw.Flush()
+
+	fmt.Printf("%d of %d podcasts added.\n", added, len(results))
+}
+
+func init() {
+	importOPMLCmd.Flags().Bool(flagFetch, false, "Fetch the feeds right away")
+	importOPMLCmd.Flags().Int(flagJobs, 4, "Number of feeds fetched at a time with --fetch")
+	importOPMLCmd.Flags().String(flagStorageRoot, "", "Directory to store the podcasts in (default <library_root>)")
+
+	importCmd.AddCommand(importOPMLCmd)
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"sort"
 
 	"github.com/jtepe/gopodgrab/pod"
 	"github.com/spf13/cobra"
@@ -15,16 +14,7 @@ var listCmd = &cobra.Command{
 These are the ones stored in the configuration file. The tool does
 not actually go look and see whether there are any episodes available`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		pods, err := pod.List()
-		if err != nil {
-			return err
-		}
-
-		sort.Slice(pods, func(i, j int) bool {
-			return pods[i].Name < pods[j].Name
-		})
-
-		printPods(pods)
+		printPods(library.Podcasts())
 
 		return nil
 	},
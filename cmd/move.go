@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var moveCmd = &cobra.Command{
+	Use:     "move <podcast> <new-dir>",
+	Example: "gopodgrab move foocast /mnt/nas/podcasts/foocast",
+	Short:   "Move the local storage of a podcast",
+	Long: `Moves the feed, episodes and all other files in the local storage of the
+podcast to a new directory and records it in the configuration.
+
+The new directory must not exist or be empty. Across file systems the files are
+copied and verified first, and the old ones are only removed once the
+configuration points to the new directory. An interrupted move is continued by
+running the same command again.`,
+
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := library.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		msg := fmt.Sprintf("Move the storage of %s from %s to %s?", p.Name, p.LocalStore, args[1])
+		if !waitApproval(msg) {
+			return nil
+		}
+
+		if err := p.Move(args[1]); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: storage moved to %s\n", p.Name, p.LocalStore)
+
+		return nil
+	},
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jtepe/gopodgrab/pod"
+	"github.com/spf13/cobra"
+)
+
+const flagTrash = "trash"
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune [<podcast>|all] [<podcast>...]",
+	Short: "Remove episodes according to the retention rules",
+	Long: `Removes downloaded episodes of the specified podcasts that break the
+podcast's retention rules. Pinned episodes are never removed.
+
+The episodes to remove are shown and have to be approved. With --trash the
+files are moved to a directory named after the podcast inside the trash
+directory instead of being deleted. Pruned episodes are not downloaded again.
+
+The special name "all" prunes all managed podcasts.`,
+
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pods, err := podsFromArgs(args)
+		if err != nil {
+			return err
+		}
+
+		dryRun, _ := cmd.Flags().GetBool(flagDryRun)
+		trash, _ := cmd.Flags().GetString(flagTrash)
+
+		return prunePods(pods, trash, dryRun)
+	},
+}
+
+func prunePods(pods []*pod.Podcast, trash string, dryRun bool) error {
+	plans := make(map[*pod.Podcast][]*pod.Prunable)
+	var numEps int
+	var totalBytes int64
+
+	for _, p := range pods {
+		prune, err := p.PlanPrune(time.Now())
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Name, err)
+		}
+
+		if len(prune) == 0 {
+			continue
+		}
+
+		plans[p] = prune
+
+		fmt.Printf("%s:\n------------------\n", p.Name)
+		for _, e := range prune {
+			fmt.Printf("%s (%s, %s): %s\n", e.Title, e.Published.Format("2006-01-02"), humanized(e.Size), e.Reason)
+
+			numEps++
+			totalBytes += e.Size
+		}
+	}
+
+	if numEps == 0 {
+		fmt.Println("No episodes to prune. Nothing to do.")
+		return nil
+	}
+
+	verb := "Delete"
+	if trash != "" {
+		verb = "Move to " + trash
+	}
+
+	msg := fmt.Sprintf("\n%s %d episodes, freeing %s?", verb, numEps, humanized(totalBytes))
+
+	if dryRun || !waitApproval(msg) {
+		return nil
+	}
+
+	for p, prune := range plans {
+		if err := p.Prune(prune, trash); err != nil {
+			return fmt.Errorf("%s: %w", p.Name, err)
+		}
+	}
+
+	fmt.Printf("Pruned %d episodes.\n", numEps)
+
+	return nil
+}
+
+func init() {
+	pruneCmd.Flags().Bool(flagDryRun, false, "Only show the episodes that would be pruned")
+	pruneCmd.Flags().String(flagTrash, "", "Directory to move pruned episodes to instead of deleting them")
+}
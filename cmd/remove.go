@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jtepe/gopodgrab/pod"
+	"github.com/spf13/cobra"
+)
+
+const flagPurge = "purge"
+
+var removeCmd = &cobra.Command{
+	Use:     "remove <podcast>...",
+	Example: "gopodgrab remove foocast barcast --purge",
+	Short:   "Stop managing podcasts",
+	Long: `Removes the specified podcasts from the configuration. Their storage
+directories, with the feed and the downloaded episodes, are kept.
+
+With --purge the storage directories are deleted along with all files in them.
+The files are shown and the removal has to be approved first.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		purge, _ := cmd.Flags().GetBool(flagPurge)
+
+		var pods []*pod.Podcast
+		var names []string
+
+		for _, arg := range args {
+			p, err := library.Get(arg)
+			if err != nil {
+				return err
+			}
+
+			pods = append(pods, p)
+			names = append(names, p.Name)
+		}
+
+		if !purge {
+			msg := fmt.Sprintf("Remove %s, keeping their files?", strings.Join(names, ", "))
+			if !waitApproval(msg) {
+				return nil
+			}
+
+			if err := library.Remove(names...); err != nil {
+				return err
+			}
+
+			fmt.Printf("Removed %s.\n", strings.Join(names, ", "))
+
+			return nil
+		}
+
+		var numFiles int
+		var totalBytes int64
+
+		for _, p := range pods {
+			files, size, err := p.StoreFiles()
+			if err != nil {
+				return fmt.Errorf("%s: %w", p.Name, err)
+			}
+
+			fmt.Printf("%s: %s\n------------------\n", p.Name, p.LocalStore)
+			for _, f := range files {
+				fmt.Println(f)
+			}
+
+			numFiles += len(files)
+			totalBytes += size
+		}
+
+		msg := fmt.Sprintf("\nRemove %s and delete %d files, freeing %s?",
+			strings.Join(names, ", "), numFiles, humanized(totalBytes))
+		if !waitApproval(msg) {
+			return nil
+		}
+
+		if err := library.Purge(names...); err != nil {
+			return err
+		}
+
+		fmt.Printf("Removed %s and deleted %d files.\n", strings.Join(names, ", "), numFiles)
+
+		return nil
+	},
+}
+
+func init() {
+	removeCmd.Flags().Bool(flagPurge, false, "Delete the storage directories with all downloaded files")
+}
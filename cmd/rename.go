@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jtepe/gopodgrab/pod"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagDryRun       = "dry-run"
+	flagFromTemplate = "from-template"
+	flagUndo         = "undo"
+)
+
+var renameCmd = &cobra.Command{
+	Use:     "rename-files [<podcast>|all] [<podcast>...]",
+	Example: "gopodgrab rename-files foocast --from-template '{{.Number}} {{.Title}}{{.Ext}}'",
+	Short:   "Re-apply the naming rules to downloaded episodes",
+	Long: `Renames the episode files in the local storage of the specified podcasts
+to the names the current file name template gives them.
+
+Files are matched to the episodes of the stored feed. Files downloaded before
+gopodgrab recorded file names are matched by the name the current template would
+give them, and by the names given by every --from-template.
+
+The planned renames are shown and have to be approved. Applied renames are kept
+in an undo log in the storage directory and --undo reverts the latest batch.
+
+The special name "all" renames the files of all managed podcasts.`,
+
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pods, err := podsFromArgs(args)
+		if err != nil {
+			return err
+		}
+
+		dryRun, _ := cmd.Flags().GetBool(flagDryRun)
+
+		if undo, _ := cmd.Flags().GetBool(flagUndo); undo {
+			return undoRenames(pods, dryRun)
+		}
+
+		fromTemplates, _ := cmd.Flags().GetStringArray(flagFromTemplate)
+
+		return renameFiles(pods, fromTemplates, dryRun)
+	},
+}
+
+func renameFiles(pods []*pod.Podcast, fromTemplates []string, dryRun bool) error {
+	plans := make(map[*pod.Podcast][]*pod.Rename)
+	var total int
+
+	for _, p := range pods {
+		renames, err := p.PlanRenames(fromTemplates...)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Name, err)
+		}
+
+		if len(renames) == 0 {
+			continue
+		}
+
+		plans[p] = renames
+		total += len(renames)
+
+		fmt.Printf("%s:\n------------------\n", p.Name)
+		printRenames(renames)
+	}
+
+	if total == 0 {
+		fmt.Println("All files are named correctly. Nothing to do.")
+		return nil
+	}
+
+	if dryRun || !waitApproval(fmt.Sprintf("\nRename %d files?", total)) {
+		return nil
+	}
+
+	for p, renames := range plans {
+		if err := p.ApplyRenames(renames); err != nil {
+			return fmt.Errorf("%s: %w", p.Name, err)
+		}
+	}
+
+	fmt.Printf("Renamed %d files.\n", total)
+
+	return nil
+}
+
+func undoRenames(pods []*pod.Podcast, dryRun bool) error {
+	if dryRun {
+		return fmt.Errorf("--%s cannot be combined with --%s", flagUndo, flagDryRun)
+	}
+
+	msg := "Revert the latest renames of"
+	for _, p := range pods {
+		msg += " " + p.Name
+	}
+
+	if !waitApproval(msg + "?") {
+		return nil
+	}
+
+	for _, p := range pods {
+		renames, err := p.UndoRenames()
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Name, err)
+		}
+
+		if len(renames) == 0 {
+			fmt.Printf("%s: nothing to undo.\n", p.Name)
+			continue
+		}
+
+		fmt.Printf("%s: reverted %d renames.\n", p.Name, len(renames))
+	}
+
+	return nil
+}
+
+// printRenames prints the renames as "old -> new" lines.
+func printRenames(renames []*pod.Rename) {
+	for _, r := range renames {
+		fmt.Printf("%s\n  -> %s\n", r.From, r.To)
+	}
+}
+
+func init() {
+	renameCmd.Flags().Bool(flagDryRun, false, "Only show the planned renames")
+	renameCmd.Flags().StringArray(flagFromTemplate, nil, "Former file name template to match files by, may be repeated")
+	renameCmd.Flags().Bool(flagUndo, false, "Revert the latest batch of renames")
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtepe/gopodgrab/pod"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagKeepLast = "keep-last"
+	flagMaxAge   = "max-age"
+	flagMaxSize  = "max-size"
+	flagPin      = "pin"
+	flagUnpin    = "unpin"
+	flagClear    = "clear"
+)
+
+var retentionCmd = &cobra.Command{
+	Use:     "retention <podcast>",
+	Example: "gopodgrab retention foocast --keep-last 20 --max-age 90d --max-size 10GB --pin \"Best of\"",
+	Short:   "Show or change the retention rules of a podcast",
+	Long: `Shows the retention rules of the specified podcast, which decide the
+episodes the prune command removes. Flags change the rules:
+
+An episode is pruned if it is not among the --keep-last most recent episodes,
+if it was published longer than --max-age ago (e.g. 36h, 90d, 4w), or if the
+total size of the podcast's episodes exceeds --max-size (e.g. 500MB, 20GB), in
+which case the oldest episodes go first. A value of zero or "" disables a rule.
+
+Episodes given to --pin by GUID, title or file name are never pruned.`,
+
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := library.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		changed, err := changeRetention(cmd, p)
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			if err := p.Save(); err != nil {
+				return err
+			}
+		}
+
+		showRetention(p)
+
+		return nil
+	},
+}
+
+// changeRetention applies the flags of cmd to the retention rules of p
+// and reports whether anything changed.
+func changeRetention(cmd *cobra.Command, p *pod.Podcast) (bool, error) {
+	flags := cmd.Flags()
+
+	// Only the flags of this command count, not the global ones.
+	changed := false
+
+	if clear, _ := flags.GetBool(flagClear); clear {
+		p.Settings.Retention = nil
+		changed = true
+	}
+
+	ret := p.Settings.Retention
+	if ret == nil {
+		ret = &pod.Retention{}
+	}
+
+	if flags.Changed(flagKeepLast) {
+		n, _ := flags.GetInt(flagKeepLast)
+		ret.KeepLast = &n
+		changed = true
+	}
+
+	if flags.Changed(flagMaxAge) {
+		ret.MaxAge, _ = flags.GetString(flagMaxAge)
+		changed = true
+	}
+
+	if flags.Changed(flagMaxSize) {
+		ret.MaxSize, _ = flags.GetString(flagMaxSize)
+		changed = true
+	}
+
+	if err := ret.Validate(); err != nil {
+		return false, err
+	}
+
+	p.Settings.Retention = ret
+
+	pins, _ := flags.GetStringArray(flagPin)
+	for _, ref := range pins {
+		titles, err := p.Pin(ref)
+		if err != nil {
+			return false, err
+		}
+
+		fmt.Printf("Pinned %s\n", strings.Join(titles, ", "))
+		changed = true
+	}
+
+	unpins, _ := flags.GetStringArray(flagUnpin)
+	for _, ref := range unpins {
+		titles, err := p.Unpin(ref)
+		if err != nil {
+			return false, err
+		}
+
+		fmt.Printf("Unpinned %s\n", strings.Join(titles, ", "))
+		changed = true
+	}
+
+	if ret.KeepLast == nil && ret.MaxAge == "" && ret.MaxSize == "" && len(ret.Pinned) == 0 {
+		p.Settings.Retention = nil
+	}
+
+	return changed, nil
+}
+
+// showRetention shows the retention rules in effect for p, including
+// the global default ones.
+func showRetention(p *pod.Podcast) {
+	ret := p.Resolved().Retention
+	if ret == nil {
+		fmt.Printf("%s keeps all episodes.\n", p.Name)
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Keep last\t%d\n", ret.KeepLastCount())
+	fmt.Fprintf(tw, "Max age\t%s\n", ret.MaxAge)
+	fmt.Fprintf(tw, "Max size\t%s\n", ret.MaxSize)
+	fmt.Fprintf(tw, "Pinned\t%d episodes\n", len(ret.Pinned))
+	tw.Flush()
+}
+
+func init() {
+	retentionCmd.Flags().Int(flagKeepLast, 0, "Number of most recent episodes to keep, 0 for all")
+	retentionCmd.Flags().String(flagMaxAge, "", "Maximum age of episodes to keep")
+	retentionCmd.Flags().String(flagMaxSize, "", "Maximum total size of episodes to keep")
+	retentionCmd.Flags().StringArray(flagPin, nil, "Episode to keep forever, may be repeated")
+	retentionCmd.Flags().StringArray(flagUnpin, nil, "Pinned episode to release, may be repeated")
+	retentionCmd.Flags().Bool(flagClear, false, "Remove all retention rules and pins")
+}
@@ -1,25 +1,128 @@
 package cmd
 
 import (
+	"errors"
 	"os"
+	"strings"
 
+	"github.com/jtepe/gopodgrab/pod"
 	"github.com/spf13/cobra"
 )
 
+// envFilenameTemplate names the environment variable holding the
+// global template for episode file names.
+const envFilenameTemplate = "GOPODGRAB_FILENAME_TEMPLATE"
+
+// Environment variables selecting the configuration file or profile.
+const (
+	envConfig  = "GOPODGRAB_CONFIG"
+	envProfile = "GOPODGRAB_PROFILE"
+)
+
+// annotationNoLoad marks commands run without loading the library, as
+// they must work even if the configuration file is broken. The library
+// passed to them is only good for its configuration methods.
+const annotationNoLoad = "gopodgrab/no-load"
+
+// allowSchemes holds the URL schemes allowed on top of http(s).
+var allowSchemes []string
+
+// configFile and profile select the configuration file.
+var configFile, profile string
+
+// library holds the managed podcasts and the global settings.
+var library *pod.Library
+
 var rootCmd = &cobra.Command{
 	Use:   "gopodgrab",
 	Short: "gopodgrab downloads your podcasts by feed URL",
 	Long: `By providing a podcast feed URL gopodgrab manages your favorite podcasts.
 It lets you download, update, and search your list of podcasts and episodes.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := selectConfig(); err != nil {
+			return err
+		}
+
+		_, noLoad := cmd.Annotations[annotationNoLoad]
+
+		return openLibrary(!noLoad)
+	},
+}
+
+// selectConfig selects the configuration file by the --config and
+// --profile flags, falling back to the environment.
+func selectConfig() error {
+	if configFile == "" {
+		configFile = os.Getenv(envConfig)
+	}
+
+	if profile == "" {
+		profile = os.Getenv(envProfile)
+	}
+
+	if configFile != "" && profile != "" {
+		return errors.New("select either a configuration file or a profile, not both")
+	}
+
+	pod.ConfigFile = configFile
+	pod.Profile = profile
+
+	return nil
+}
+
+// openLibrary loads the managed podcasts and global settings from the
+// configuration file. Without load, the library is only set up for the
+// configuration commands.
+func openLibrary(load bool) error {
+	open := pod.OpenLibrary
+	if !load {
+		open = pod.DefaultLibrary
+	}
+
+	lib, err := open()
+	if err != nil {
+		return err
+	}
+
+	library = lib
+
+	return nil
 }
 
 func init() {
+	cobra.OnInitialize(func() {
+		pod.FilenameTemplate = os.Getenv(envFilenameTemplate)
+
+		for _, s := range allowSchemes {
+			pod.AllowedSchemes[strings.ToLower(s)] = true
+		}
+	})
+
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "",
+		"Configuration file to use (default $"+envConfig+" or gopodgrab.json in the user config directory)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "",
+		"Profile with its own configuration file, podcasts and settings (default $"+envProfile+")")
+	rootCmd.PersistentFlags().StringSliceVar(&allowSchemes, "allow-scheme", nil,
+		"Additional URL schemes to fetch feeds and episodes from (http and https are always allowed)")
+
 	rootCmd.AddCommand(addCmd,
 		listCmd,
 		showCmd,
 		versionCmd,
 		updateCmd,
-		doctorCmd)
+		doctorCmd,
+		renameCmd,
+		pruneCmd,
+		retentionCmd,
+		feedCmd,
+		moveCmd,
+		configCmd,
+		importCmd,
+		exportCmd,
+		subscriptionsCmd,
+		removeCmd,
+		editCmd,
+		episodesCmd)
 }
 
 func Execute() {
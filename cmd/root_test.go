@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jtepe/gopodgrab/pod"
+)
+
+// runRoot runs gopodgrab with args and resets the selected
+// configuration afterwards.
+func runRoot(t *testing.T, args ...string) error {
+	t.Helper()
+
+	t.Cleanup(func() {
+		configFile, profile = "", ""
+		pod.ConfigFile, pod.Profile = "", ""
+		library = nil
+	})
+
+	rootCmd.SetArgs(args)
+	rootCmd.SetOut(ioutil.Discard)
+	rootCmd.SetErr(ioutil.Discard)
+
+	return rootCmd.Execute()
+}
+
+// captureStdout returns what run prints to the standard output.
+func captureStdout(t *testing.T, run func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+
+	done := make(chan []byte)
+	go func() {
+		buf, _ := ioutil.ReadAll(r)
+		done <- buf
+	}()
+
+	run()
+
+	os.Stdout = stdout
+	w.Close()
+
+	return string(<-done)
+}
+
+func TestBrokenConfig(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the editor is a shell script")
+	}
+
+	dir := t.TempDir()
+	cf := filepath.Join(dir, "broken.json")
+
+	if err := ioutil.WriteFile(cf, []byte(`{"version": 3, "podcasts": {`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runRoot(t, "--config", cf, "version"); err != nil {
+		t.Errorf("version: %v", err)
+	}
+
+	if err := runRoot(t, "--config", cf, "list"); err == nil {
+		t.Error("list: loaded a broken configuration file")
+	}
+
+	// The editor repairs the file.
+	fixed := `{"version": 3, "settings": {"reserve": "5GB"}}`
+	editor := filepath.Join(dir, "editor.sh")
+	script := "#!/bin/sh\necho '" + fixed + "' > \"$1\"\n"
+
+	if err := ioutil.WriteFile(editor, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	old, ok := os.LookupEnv("VISUAL")
+	os.Setenv("VISUAL", editor)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv("VISUAL", old)
+		} else {
+			os.Unsetenv("VISUAL")
+		}
+	})
+
+	if err := runRoot(t, "--config", cf, "config", "edit"); err != nil {
+		t.Fatalf("config edit: %v", err)
+	}
+
+	buf, err := ioutil.ReadFile(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != fixed+"\n" {
+		t.Errorf("configuration file not replaced by the edited one: %s", buf)
+	}
+
+	if err := runRoot(t, "--config", cf, "list"); err != nil {
+		t.Errorf("list after repairing: %v", err)
+	}
+}
+
+func TestDoctorConfigVersion(t *testing.T) {
+	cf := filepath.Join(t.TempDir(), "old.json")
+
+	// Version 1 files are a bare map of podcasts.
+	if err := ioutil.WriteFile(cf, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	out := captureStdout(t, func() { err = runRoot(t, "--config", cf, "doctor") })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out, "format version 1\n") {
+		t.Errorf("outdated version not reported:\n%s", out)
+	}
+
+	out = captureStdout(t, func() { err = runRoot(t, "--config", cf, "doctor") })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "format version " + strconv.Itoa(pod.ConfigVersion) + "\n"; !strings.Contains(out, want) {
+		t.Errorf("expected %q once migrated:\n%s", want, out)
+	}
+}
+
+func TestRetentionUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	cf := filepath.Join(dir, "gopodgrab.json")
+
+	conf := `{"version": 3, "podcasts": {"foo": {"name": "foo", "feed_url": "http://example.com/feed.xml", "local_store": "` +
+		filepath.ToSlash(filepath.Join(dir, "foo")) + `"}}}`
+	if err := ioutil.WriteFile(cf, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Global flags alone change nothing, the file is left alone.
+	captureStdout(t, func() {
+		if err := runRoot(t, "--config", cf, "retention", "foo"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if buf, err := ioutil.ReadFile(cf); err != nil || string(buf) != conf {
+		t.Errorf("configuration file rewritten without a change: %s, %v", buf, err)
+	}
+
+	captureStdout(t, func() {
+		if err := runRoot(t, "--config", cf, "retention", "foo", "--max-age", "30d"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if buf, err := ioutil.ReadFile(cf); err != nil || !strings.Contains(string(buf), `"max_age": "30d"`) {
+		t.Errorf("retention rule not saved: %s, %v", buf, err)
+	}
+}
@@ -14,10 +14,11 @@ var showCmd = &cobra.Command{
 	Example: "gopodgrab show FooPodcast",
 	Short:   "Short summary of a managed podcast",
 	Long: `Display a short summary of the specified managed podcast.
-Shows all properties of the podcast.`,
+Shows all properties of the podcast and the settings in effect for it,
+including the global defaults.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		p, err := pod.Get(args[0])
+		p, err := library.Get(args[0])
 		if err != nil {
 			return err
 		}
@@ -32,5 +33,26 @@ func show(p *pod.Podcast) {
 	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', tabwriter.AlignRight)
 	fmt.Fprintf(tw, "Name\t%s\n", p.Name)
 	fmt.Fprintf(tw, "Episodes directory\t%s\n", p.LocalStore)
+	if p.Category != "" {
+		fmt.Fprintf(tw, "Category\t%s\n", p.Category)
+	}
+
+	s := p.Resolved()
+	fmt.Fprintf(tw, "Paused\t%t\n", s.Paused)
+	fmt.Fprintf(tw, "Auto approve\t%t\n", s.AutoApprove)
+	fmt.Fprintf(tw, "Filename template\t%s\n", s.FilenameTemplate)
+	fmt.Fprintf(tw, "Download order\t%s\n", s.DownloadOrder)
+	if s.MaxEpisodes > 0 {
+		fmt.Fprintf(tw, "Max episodes per run\t%d\n", s.MaxEpisodes)
+	}
+	if s.EnclosureType != "" {
+		fmt.Fprintf(tw, "Enclosure type\t%s\n", s.EnclosureType)
+	}
+	if s.Retention != nil {
+		fmt.Fprintf(tw, "Retention\tkeep last %d, max age %q, max size %q, %d pinned\n",
+			s.Retention.KeepLastCount(), s.Retention.MaxAge, s.Retention.MaxSize, len(s.Retention.Pinned))
+	}
+	fmt.Fprintf(tw, "Tag files\t%t\n", s.TagFiles)
+	fmt.Fprintf(tw, "Episode artwork\t%t\n", s.EpisodeArtwork)
 	tw.Flush()
 }
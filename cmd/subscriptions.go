@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtepe/gopodgrab/pod"
+	"github.com/spf13/cobra"
+)
+
+const flagUnlisted = "unlisted"
+
+var subscriptionsCmd = &cobra.Command{
+	Use:   "subscriptions",
+	Short: "Keep the podcasts in sync with a subscription list",
+}
+
+var subscriptionsSyncCmd = &cobra.Command{
+	Use:     "sync <url>",
+	Example: "gopodgrab subscriptions sync https://intranet.example.com/podcasts.opml --unlisted pause",
+	Short:   "Sync the podcasts with an OPML subscription list",
+	Long: `Fetches the OPML subscription list at the URL and adds the podcasts missing
+from the library, as import opml does. Podcasts managed already are synced with
+the list from now on.
+
+Podcasts synced with the list that are no longer listed are left alone, or with
+--unlisted paused or removed. Removing a podcast keeps its files. A listed feed
+matching a synced podcast by name or title, but not by feed URL, is taken as a
+new feed URL of the podcast, which is changed and reported.
+
+With --fetch the feeds of added podcasts are fetched right away, otherwise by
+their next update.
+
+With --dry-run the changes are only shown.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source := args[0]
+		unlisted := cmd.Flag(flagUnlisted).Value.String()
+		dryRun, _ := cmd.Flags().GetBool(flagDryRun)
+		fetch, _ := cmd.Flags().GetBool(flagFetch)
+		jobs, _ := cmd.Flags().GetInt(flagJobs)
+		root := cmd.Flag(flagStorageRoot).Value.String()
+
+		if !fetch {
+			jobs = 0
+		} else if jobs < 1 {
+			return errors.New("at least one job is needed to fetch feeds")
+		}
+
+		subs, err := pod.FetchOPML(source)
+		if err != nil {
+			return err
+		}
+
+		changes, err := library.PlanSync(source, subs, unlisted)
+		if err != nil {
+			return err
+		}
+
+		if len(changes) == 0 {
+			fmt.Println("Podcasts are in sync.")
+			return nil
+		}
+
+		if dryRun {
+			printSync(changes)
+			return nil
+		}
+
+		var removed []string
+		adding := false
+
+		for _, c := range changes {
+			switch c.Action {
+			case pod.SyncRemove:
+				removed = append(removed, c.Podcast.Name)
+			case pod.SyncAdd:
+				adding = true
+			}
+		}
+
+		if adding && root == "" && library.Root() == "" {
+			return pod.ErrNoStorage
+		}
+
+		if len(removed) > 0 {
+			msg := fmt.Sprintf("Remove %s, no longer listed, keeping their files?", strings.Join(removed, ", "))
+			if !waitApproval(msg) {
+				return nil
+			}
+		}
+
+		err = library.ApplySync(source, changes, root, jobs)
+
+		printSync(changes)
+
+		return err
+	},
+}
+
+// printSync prints the changes of a subscription sync as a table.
+func printSync(changes []*pod.SyncChange) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tNAME\tDETAILS")
+
+	for _, c := range changes {
+		name := "-"
+		if c.Podcast != nil {
+			name = c.Podcast.Name
+		}
+
+		var details string
+
+		switch c.Action {
+		case pod.SyncAdd:
+			details = c.Sub.Title + " from " + c.Sub.FeedURL
+		case pod.SyncAdopt:
+			details = "listed, managed already"
+		case pod.SyncFeedURL:
+			details = "feed URL changed from " + c.OldFeedURL + " to " + c.Sub.FeedURL
+		case pod.SyncPause, pod.SyncRemove, pod.SyncKeep:
+			details = "no longer listed"
+		}
+
+		if c.Err != nil {
+			details += ", failed: " + c.Err.Error()
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Action, name, details)
+	}
+
+	tw.Flush()
+}
+
+func init() {
+	subscriptionsSyncCmd.Flags().String(flagUnlisted, pod.SyncKeep,
+		"What to do with podcasts no longer listed: keep, pause or remove")
+	subscriptionsSyncCmd.Flags().Bool(flagDryRun, false, "Only show the changes")
+	subscriptionsSyncCmd.Flags().Bool(flagFetch, false, "Fetch the feeds of added podcasts right away")
+	subscriptionsSyncCmd.Flags().Int(flagJobs, 4, "Number of feeds fetched at a time with --fetch")
+	subscriptionsSyncCmd.Flags().String(flagStorageRoot, "", "Directory to store added podcasts in (default <library_root>)")
+
+	subscriptionsCmd.AddCommand(subscriptionsSyncCmd)
+}
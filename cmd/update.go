@@ -2,46 +2,120 @@ package cmd
 
 import (
 	"fmt"
+	"log"
+	"os"
 
 	"github.com/jtepe/gopodgrab/pod"
 	"github.com/spf13/cobra"
 )
 
+const (
+	flagRefetchChanged = "refetch-changed"
+	flagTag            = "tag"
+	flagReserve        = "reserve"
+)
+
+// envReserve names the environment variable holding the free space to
+// leave on file systems when downloading.
+const envReserve = "GOPODGRAB_RESERVE"
+
 var updateCmd = &cobra.Command{
 	Use:   "update [<podcast>|all] [<podcast>...]",
 	Short: "Updates the specifed podcast",
 	Long: `Updates the specified podcast's episodes, downloading all
 episodes that are not yet present in the local storage.
 
+Downloaded episodes that the publisher removed from the feed, or whose
+enclosure URL or length changed since, are flagged once when first noticed.
+Changed episodes are downloaded again with --refetch-changed.
+
+The podcast cover art is saved as cover.jpg and folder.jpg, and episode
+images next to the episodes for podcasts added with --episode-artwork.
+Artwork is only downloaded again when its URL changes.
+
+Before downloading, the free space of the file systems holding the podcasts
+is checked. Podcasts whose episodes don't fit while leaving --reserve bytes
+free (default $` + envReserve + ` or 1GB) are skipped.
+
+With --tag feed metadata is written into the downloaded files, as it is for
+podcasts added with --tag-files.
+
+Paused podcasts are skipped. New episodes are downloaded in the download order
+of the podcast, up to its maximum number of episodes per run. Downloads of
+podcasts set to auto approve start without asking.
+
 The special name "all" updates all managed podcasts.`,
 
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		pods := make([]*pod.Podcast, 0, len(args))
+		pods, err := podsFromArgs(args)
+		if err != nil {
+			return err
+		}
 
-		for _, arg := range args {
-			if arg == pod.ReservedPodName {
-				all, err := pod.List()
-				if err != nil {
-					return err
-				}
+		refetch, _ := cmd.Flags().GetBool(flagRefetchChanged)
 
-				return updatePods(all)
-			}
+		reserve, err := reserveBytes(cmd.Flag(flagReserve).Value.String())
+		if err != nil {
+			return err
+		}
+
+		tag, _ := cmd.Flags().GetBool(flagTag)
+		active := pods[:0]
 
-			p, err := pod.Get(arg)
-			if err != nil {
-				return err
+		for _, p := range pods {
+			if p.Resolved().Paused {
+				fmt.Printf("%s: paused, skipping\n", p.Name)
+				continue
 			}
 
-			pods = append(pods, p)
+			active = append(active, p)
+		}
+
+		if err := updatePods(active, refetch, tag, reserve); err != nil {
+			return err
 		}
 
-		return updatePods(pods)
+		updateArtwork(active)
+
+		return nil
 	},
 }
 
-func updatePods(pods []*pod.Podcast) error {
+// updateArtwork saves the artwork of the podcasts. Failures are logged
+// as they don't affect the episodes.
+func updateArtwork(pods []*pod.Podcast) {
+	for _, p := range pods {
+		if err := p.UpdateArtwork(); err != nil {
+			log.Printf("%s: failed to update artwork: %v", p.Name, err)
+		}
+	}
+}
+
+// reserveBytes parses the reserve given by flag, falling back to the
+// environment, the global settings and pod.DefaultReserve.
+func reserveBytes(flag string) (int64, error) {
+	if flag == "" {
+		flag = os.Getenv(envReserve)
+	}
+
+	if flag == "" {
+		flag = library.Settings().Reserve
+	}
+
+	if flag == "" {
+		return pod.DefaultReserve, nil
+	}
+
+	reserve, err := pod.ParseSize(flag)
+	if err != nil {
+		return 0, fmt.Errorf("reserve: %w", err)
+	}
+
+	return reserve, nil
+}
+
+func updatePods(pods []*pod.Podcast, refetch, tag bool, reserve int64) error {
 	newEps := make(map[*pod.Podcast][]*pod.Episode)
 
 	for _, p := range pods {
@@ -50,7 +124,26 @@ func updatePods(pods []*pod.Podcast) error {
 			return err
 		}
 
-		newEps[p] = eps
+		eps = p.SelectDownloads(eps)
+
+		changes, err := p.UpstreamChanges()
+		if err != nil {
+			return err
+		}
+
+		printUpstreamChanges(p, changes, refetch)
+
+		if refetch {
+			for _, c := range changes {
+				if c.Kind == pod.Modified {
+					eps = append(eps, c.Episode)
+				}
+			}
+		}
+
+		if len(eps) > 0 {
+			newEps[p] = eps
+		}
 	}
 
 	if len(newEps) == 0 {
@@ -58,6 +151,27 @@ func updatePods(pods []*pod.Podcast) error {
 		return nil
 	}
 
+	for _, s := range pod.CheckSpace(newEps, reserve) {
+		if s.Err != nil {
+			log.Printf("%s: skipped, checking free space on %s failed: %v", s.Podcast.Name, s.Podcast.LocalStore, s.Err)
+			delete(newEps, s.Podcast)
+			continue
+		}
+
+		free := s.Free
+		if free < 0 {
+			free = 0
+		}
+
+		fmt.Printf("%s: skipped, %s needed but only %s free on %s (keeping %s in reserve)\n",
+			s.Podcast.Name, humanized(s.Need), humanized(free), s.Podcast.LocalStore, humanized(reserve))
+		delete(newEps, s.Podcast)
+	}
+
+	if len(newEps) == 0 {
+		return nil
+	}
+
 	for p, eps := range newEps {
 		fmt.Printf("%s:\n------------------\n", p.Name)
 		for _, e := range eps {
@@ -67,7 +181,11 @@ func updatePods(pods []*pod.Podcast) error {
 
 	var numEps int
 	var totalBytes int64
-	for _, eps := range newEps {
+	for p, eps := range newEps {
+		if p.Resolved().AutoApprove {
+			continue
+		}
+
 		numEps += len(eps)
 
 		for _, e := range eps {
@@ -75,16 +193,55 @@ func updatePods(pods []*pod.Podcast) error {
 		}
 	}
 
-	bytesHuman := humanized(totalBytes)
-	msg := fmt.Sprintf("\nDownload %d episodes for %s?", numEps, bytesHuman)
+	approved := numEps == 0
+	if !approved {
+		bytesHuman := humanized(totalBytes)
+		msg := fmt.Sprintf("\nDownload %d episodes for %s?", numEps, bytesHuman)
+		approved = waitApproval(msg)
+	}
 
-	if waitApproval(msg) {
-		for p, eps := range newEps {
-			if err := p.DownloadEpisodes(eps); err != nil {
-				return err
-			}
+	for p, eps := range newEps {
+		if !approved && !p.Resolved().AutoApprove {
+			continue
+		}
+
+		if err := p.DownloadEpisodes(eps, tag); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
+
+// printUpstreamChanges reports the newly noticed upstream changes of the
+// podcast's downloaded episodes, and those about to be refetched. Changed
+// episodes not being refetched are counted.
+func printUpstreamChanges(p *pod.Podcast, changes []*pod.UpstreamChange, refetch bool) {
+	var pending int
+
+	for _, c := range changes {
+		if c.Kind == pod.Modified && !refetch {
+			pending++
+		}
+
+		if !c.New && !(refetch && c.Kind == pod.Modified) {
+			continue
+		}
+
+		fmt.Printf("%s: %s (%s): %s\n", p.Name, c.Title, c.File, c.Kind)
+		for _, change := range c.Changes {
+			fmt.Printf("    %s\n", change)
+		}
+	}
+
+	if pending > 0 {
+		fmt.Printf("%s: %d downloaded episodes changed upstream, use --%s to download them again\n",
+			p.Name, pending, flagRefetchChanged)
+	}
+}
+
+func init() {
+	updateCmd.Flags().Bool(flagRefetchChanged, false, "Download episodes again that changed upstream")
+	updateCmd.Flags().Bool(flagTag, false, "Write feed metadata into the downloaded files")
+	updateCmd.Flags().String(flagReserve, "", "Free space to leave on file systems, e.g. 500MB or 2GB")
+}
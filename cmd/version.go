@@ -9,9 +9,10 @@ import (
 var Version = "development"
 
 var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Print version",
-	Long:  "Show the full version information of gopodgrab.",
+	Use:         "version",
+	Short:       "Print version",
+	Long:        "Show the full version information of gopodgrab.",
+	Annotations: map[string]string{annotationNoLoad: ""},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("gopodgrab", Version)
 	},
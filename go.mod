@@ -3,9 +3,11 @@ module github.com/jtepe/gopodgrab
 go 1.15
 
 require (
+	github.com/BurntSushi/toml v0.4.1
 	github.com/schollz/progressbar/v3 v3.7.2
 	github.com/spf13/cobra v1.1.0
+	github.com/spf13/pflag v1.0.5
 	golang.org/x/crypto v0.0.0-20201221181555-eec23a3978ad // indirect
-	golang.org/x/sys v0.0.0-20201223074533-0d417f636930 // indirect
+	golang.org/x/sys v0.0.0-20201223074533-0d417f636930
 	golang.org/x/term v0.0.0-20201210144234-2321bbc49cbf // indirect
 )
@@ -0,0 +1,251 @@
+package pod
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Base names of the podcast cover art files in the local store. Media
+// servers and car stereos look for one or the other.
+var coverBaseNames = []string{"cover", "folder"}
+
+// storedImage is the record of artwork saved in the local store.
+type storedImage struct {
+	URL   string   `json:"url"`   // URL the image was downloaded from
+	Files []string `json:"files"` // Names of the image files in the local store
+}
+
+// imageExt returns the file extension for images of MIME type mime.
+func imageExt(mime string) string {
+	if mime == "image/png" {
+		return ".png"
+	}
+
+	return ".jpg"
+}
+
+// isImageFile reports whether the file name is the one of artwork.
+func isImageFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+
+	return false
+}
+
+// UpdateArtwork saves the cover art of the podcast as cover.jpg and
+// folder.jpg (or .png) in the local store. With EpisodeArtwork set,
+// downloaded episodes with an image of their own get it saved next to
+// the episode file, named like it. Images are only downloaded again if
+// their URL changed or the file went missing. Images that cannot be
+// fetched are logged and skipped.
+func (pod *Podcast) UpdateArtwork() error {
+	ch, _, err := pod.readFeedChannel()
+	if err != nil {
+		return err
+	}
+
+	idx, err := pod.readIndex()
+	if err != nil {
+		return err
+	}
+
+	fetched := make(map[string]*coverArt)
+	fetch := func(url string) *coverArt {
+		if img, ok := fetched[url]; ok {
+			return img
+		}
+
+		data, mime, err := fetchImage(url)
+		if err != nil {
+			log.Printf("%s: failed to fetch artwork: %v", pod.Name, err)
+		} else {
+			fetched[url] = &coverArt{data: data, mime: mime}
+		}
+
+		return fetched[url]
+	}
+
+	dirty := false
+
+	if ch.Image != "" && !pod.imagePresent(idx.Cover, ch.Image, nil) {
+		if img := fetch(ch.Image); img != nil {
+			files := make([]string, len(coverBaseNames))
+			for i, base := range coverBaseNames {
+				files[i] = base + imageExt(img.mime)
+			}
+
+			if idx.Cover, err = pod.saveImage(idx.Cover, ch.Image, img, files); err != nil {
+				return err
+			}
+
+			dirty = true
+		}
+	}
+
+	if pod.Resolved().EpisodeArtwork {
+		changed, err := pod.updateEpisodeArtwork(ch, idx, fetch)
+		if err != nil {
+			return err
+		}
+
+		dirty = dirty || changed
+	}
+
+	if !dirty {
+		return nil
+	}
+
+	return pod.writeIndex(idx)
+}
+
+// updateEpisodeArtwork saves the images of the downloaded episodes
+// differing from the podcast cover. Images of renamed episode files
+// are renamed along. Reports whether idx changed.
+func (pod *Podcast) updateEpisodeArtwork(ch *Channel, idx *storeIndex, fetch func(string) *coverArt) (bool, error) {
+	eps, err := pod.readFeed()
+	if err != nil {
+		return false, err
+	}
+
+	dirty := false
+
+	for _, e := range eps {
+		se, ok := idx.Episodes[episodeKey(e)]
+		url := string(e.Image)
+
+		if !ok || !se.Pruned.IsZero() || url == "" || url == ch.Image {
+			continue
+		}
+
+		base := strings.TrimSuffix(se.File, filepath.Ext(se.File))
+
+		if se.Image != nil && se.Image.URL == url && len(se.Image.Files) == 1 {
+			want := base + filepath.Ext(se.Image.Files[0])
+			if pod.imagePresent(se.Image, url, []string{want}) {
+				continue
+			}
+
+			if moved, err := pod.moveImage(se.Image, want); err != nil {
+				return dirty, err
+			} else if moved {
+				dirty = true
+				continue
+			}
+		}
+
+		img := fetch(url)
+		if img == nil {
+			continue
+		}
+
+		if se.Image, err = pod.saveImage(se.Image, url, img, []string{base + imageExt(img.mime)}); err != nil {
+			return dirty, err
+		}
+
+		dirty = true
+	}
+
+	return dirty, nil
+}
+
+// imagePresent reports whether the image recorded by si was downloaded
+// from url and all its files are in the local store. If files is set,
+// the recorded files must match it.
+func (pod *Podcast) imagePresent(si *storedImage, url string, files []string) bool {
+	if si == nil || si.URL != url || len(si.Files) == 0 {
+		return false
+	}
+
+	if files != nil && strings.Join(files, "\x00") != strings.Join(si.Files, "\x00") {
+		return false
+	}
+
+	for _, f := range si.Files {
+		if !fileExists(filepath.Join(pod.LocalStore, f)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// moveImage renames the single file of the image recorded by si to
+// file. Reports false if there is no file to rename.
+func (pod *Podcast) moveImage(si *storedImage, file string) (bool, error) {
+	src, err := safeJoin(pod.LocalStore, si.Files[0])
+	if err != nil {
+		return false, err
+	}
+
+	dst, err := safeJoin(pod.LocalStore, file)
+	if err != nil {
+		return false, err
+	}
+
+	if err := os.Rename(src, dst); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	si.Files = []string{file}
+
+	return true, nil
+}
+
+// saveImage writes img to files in the local store and returns the new
+// record of the image. Files of the previous record old not written
+// again are removed.
+func (pod *Podcast) saveImage(old *storedImage, url string, img *coverArt, files []string) (*storedImage, error) {
+	for _, f := range files {
+		path, err := safeJoin(pod.LocalStore, f)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := writeFileAtomic(path, img.data, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	if old != nil {
+		pod.removeImage(old, files...)
+	}
+
+	return &storedImage{URL: url, Files: files}, nil
+}
+
+// removeImage removes the files of the image recorded by si from the
+// local store, except for those in keep. Failures are logged.
+func (pod *Podcast) removeImage(si *storedImage, keep ...string) {
+	for _, f := range si.Files {
+		if containsString(keep, f) {
+			continue
+		}
+
+		path, err := safeJoin(pod.LocalStore, f)
+		if err == nil {
+			err = os.Remove(path)
+		}
+
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("%s: failed to remove artwork: %v", pod.Name, err)
+		}
+	}
+}
+
+// containsString reports whether s is in list.
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
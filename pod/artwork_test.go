@@ -0,0 +1,91 @@
+package pod
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdateArtwork(t *testing.T) {
+	jpeg := "\xff\xd8\xff\xe0 cover"
+	fetches := make(map[string]int)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches[r.URL.Path]++
+
+		if r.URL.Path == "/feed.xml" {
+			fmt.Fprintf(w, `<rss xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel>
+<title>Foocast</title>
+<image><url>http://%[1]s/small.jpg</url></image>
+<itunes:image href="http://%[1]s/cover.jpg"/>
+<item><title>One</title><guid>foo-1</guid><enclosure url="http://%[1]s/one.mp3" length="3"/>
+<itunes:image href="http://%[1]s/one.jpg"/></item>
+<item><title>Two</title><guid>foo-2</guid><enclosure url="http://%[1]s/two.mp3" length="3"/>
+<itunes:image href="http://%[1]s/cover.jpg"/></item>
+</channel></rss>`, r.Host)
+			return
+		}
+
+		_, _ = io.WriteString(w, jpeg)
+	}))
+	defer srv.Close()
+
+	yes := true
+	p := &Podcast{Name: "foocast", FeedURL: srv.URL + "/feed.xml", LocalStore: t.TempDir()}
+	p.Settings.EpisodeArtwork = &yes
+
+	if err := p.RefreshFeed(); err != nil {
+		t.Fatal(err)
+	}
+
+	eps, err := p.NewEpisodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := p.readIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range eps {
+		if err := ioutil.WriteFile(filepath.Join(p.LocalStore, e.file), []byte("mp3"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		idx.record(e, e.file, time.Now())
+	}
+
+	if err := p.writeIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := p.UpdateArtwork(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, f := range []string{"cover.jpg", "folder.jpg", "One.jpg"} {
+		if buf, err := ioutil.ReadFile(filepath.Join(p.LocalStore, f)); err != nil || string(buf) != jpeg {
+			t.Errorf("%s: got %q, %v", f, buf, err)
+		}
+	}
+
+	if fileExists(filepath.Join(p.LocalStore, "Two.jpg")) {
+		t.Error("episode image equal to the cover was saved")
+	}
+
+	if fetches["/cover.jpg"] != 1 || fetches["/one.jpg"] != 1 || fetches["/small.jpg"] != 0 {
+		t.Errorf("unexpected image fetches %v", fetches)
+	}
+
+	if eps, err := p.NewEpisodes(); err != nil || len(eps) != 0 {
+		t.Errorf("artwork taken for episodes: %v, %v", eps, err)
+	}
+}
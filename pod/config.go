@@ -7,97 +7,362 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"regexp"
 )
 
-// addPod adds the podcast to the configuration file.
-// If  creating/writing of the file fails, an error is returned.
-func addPod(pod *Podcast) error {
-	pods, err := readPods()
-	if err != nil {
-		return err
+// ConfigVersion is the version of the configuration file format
+// written by this version of gopodgrab.
+const ConfigVersion = 3
+
+// Config is the content of the configuration file.
+type Config struct {
+	Version  int                 `json:"version"`  // Version of the file format
+	Settings *Settings           `json:"settings"` // Global settings
+	Podcasts map[string]*Podcast `json:"podcasts"` // Managed podcasts by name
+}
+
+// Settings are the global settings of gopodgrab, along with the
+// defaults of the podcast settings.
+type Settings struct {
+	PodSettings
+	Reserve     string `json:"reserve,omitempty"`      // Free space to leave on file systems when downloading
+	LibraryRoot string `json:"library_root,omitempty"` // Directory holding the storage of podcasts added without one
+}
+
+// migrations upgrade the configuration file. The migration at index i
+// turns version i+1 into version i+2.
+var migrations = []func(buf []byte) ([]byte, error){
+	migrateV1,
+	migrateV2,
+}
+
+// migrateV1 wraps the bare map of podcasts of version 1 into a
+// versioned document.
+func migrateV1(buf []byte) ([]byte, error) {
+	var pods map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &pods); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"version":  2,
+		"settings": struct{}{},
+		"podcasts": pods,
+	})
+}
+
+// podSettingKeys are the podcast settings kept with the podcast itself
+// up to version 2.
+var podSettingKeys = []string{"filename_template", "retention", "tag_files", "episode_artwork"}
+
+// migrateV2 moves the settings of the podcasts into their settings
+// block.
+func migrateV2(buf []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+
+	var pods map[string]map[string]json.RawMessage
+	if len(doc["podcasts"]) > 0 {
+		if err := json.Unmarshal(doc["podcasts"], &pods); err != nil {
+			return nil, err
+		}
 	}
 
-	pods[pod.Name] = pod
+	for _, p := range pods {
+		settings := make(map[string]json.RawMessage)
+
+		for _, key := range podSettingKeys {
+			if v, ok := p[key]; ok {
+				settings[key] = v
+				delete(p, key)
+			}
+		}
+
+		buf, err := json.Marshal(settings)
+		if err != nil {
+			return nil, err
+		}
+
+		p["settings"] = buf
+	}
 
-	buf, err := json.MarshalIndent(&pods, "", "  ")
+	podsBuf, err := json.Marshal(pods)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := ioutil.WriteFile(confFile(), buf, 0644); err != nil {
-		return err
+	doc["podcasts"] = podsBuf
+	doc["version"] = json.RawMessage("3")
+
+	return json.Marshal(doc)
+}
+
+// configVersion returns the version of the configuration file content
+// buf. Version 1 files lack the version field, an empty file is of the
+// current version.
+func configVersion(buf []byte) (int, error) {
+	if len(buf) == 0 {
+		return ConfigVersion, nil
 	}
 
-	return nil
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return 0, err
+	}
+
+	// A podcast named "version" has an object rather than a number.
+	var v int
+	if err := json.Unmarshal(doc["version"], &v); err != nil || v == 0 {
+		return 1, nil
+	}
+
+	return v, nil
 }
 
-// podExists checks whether a podcast by that name is
-// already present in the configuration file.
-func podExists(name string) bool {
-	pods, err := readPods()
+// decodeConfig decodes the configuration file content buf, migrating
+// it to the current version. It returns the version found.
+func decodeConfig(buf []byte) (*Config, int, error) {
+	buf, version, err := migrateConfig(buf)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to read configuration file at %s: %v\n", confFile(), err)
-		os.Exit(1)
+		return nil, version, err
 	}
 
-	for _, pod := range pods {
-		if pod.Name == name {
-			return true
+	conf := &Config{Version: ConfigVersion}
+
+	if len(buf) > 0 {
+		if err := json.Unmarshal(buf, conf); err != nil {
+			return nil, version, err
 		}
 	}
 
-	return false
+	conf.init()
+
+	return conf, version, nil
 }
 
-// confFile returns the storage location of gopodcrab's configuration
-// file. It uses the user's default config directory as base the exact
-// location of which is OS dependent. Otherwise, the current working
-// directory is used. '/.gopodgrab' is appended to the base in any case.
-func confFile() string {
-	cf, err := os.UserConfigDir()
+// migrateConfig migrates the configuration file content buf to the
+// current version. It returns the migrated content and the version
+// found.
+func migrateConfig(buf []byte) ([]byte, int, error) {
+	version, err := configVersion(buf)
 	if err != nil {
-		cf = "."
+		return nil, 0, err
+	}
+
+	if version > ConfigVersion {
+		return nil, version, fmt.Errorf("%w: version %d, supported up to %d", ErrConfigVersion, version, ConfigVersion)
+	}
+
+	for v := version; v < ConfigVersion; v++ {
+		if buf, err = migrations[v-1](buf); err != nil {
+			return nil, version, fmt.Errorf("migrating configuration from version %d: %w", v, err)
+		}
+	}
+
+	return buf, version, nil
+}
+
+// init fills in the sections missing from conf.
+func (conf *Config) init() {
+	if conf.Settings == nil {
+		conf.Settings = new(Settings)
+	}
+
+	if conf.Podcasts == nil {
+		conf.Podcasts = make(map[string]*Podcast)
+	}
+}
+
+// writeConfig replaces the content old of the configuration file cf,
+// of the given format version, by out. The caller holds the lock.
+func writeConfig(cf string, old []byte, version int, out []byte) error {
+	if len(old) > 0 {
+		if version < ConfigVersion {
+			if err := writeFileAtomic(fmt.Sprintf("%s.v%d.bak", cf, version), old, 0644); err != nil {
+				return err
+			}
+		}
+
+		if err := writeFileAtomic(cf+".bak", old, 0644); err != nil {
+			return err
+		}
 	}
 
-	cf = cf + "/gopodgrab/gopodgrab.json"
-	return cf
+	return writeFileAtomic(cf, out, 0644)
 }
 
-// readPods retrieves all podcasts from the configuration file
-// indexed by the name of the podcast. If the file doesn't, it
-// is created and an empty map of podcasts is returned.
-// Errors reading the file are passed back to the caller.
-func readPods() (map[string]*Podcast, error) {
-	pods := make(map[string]*Podcast)
+// ConfigInfo returns the location of the configuration file of the
+// library and the version of its format, as found in the file before
+// any migration. Libraries not stored in a file have no location and
+// are of the current version.
+func (lib *Library) ConfigInfo() (string, int, error) {
+	fs, ok := lib.store.(*FileStore)
+	if !ok {
+		return "", ConfigVersion, nil
+	}
 
-	cf := confFile()
+	cf := fs.Path
 
-	f, err := os.Open(cf)
+	buf, err := readConfFile(cf)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			err = createConfFile(cf)
-			return pods, err
+		return cf, 0, err
+	}
+
+	if isTOML(cf) {
+		if buf, err = tomlToJSON(buf); err != nil {
+			return cf, 0, err
 		}
+	}
 
-		return nil, err
+	version, err := configVersion(buf)
+
+	return cf, version, err
+}
+
+// decodeConfigFile decodes the content buf of the configuration file
+// cf as decodeConfig does, taking its format into account.
+func decodeConfigFile(cf string, buf []byte) (*Config, int, error) {
+	if isTOML(cf) {
+		var err error
+		if buf, err = tomlToJSON(buf); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return decodeConfig(buf)
+}
+
+// encodeConfig encodes conf for the configuration file cf in its
+// format. Comments of the previous TOML content old are kept.
+func encodeConfig(cf string, conf *Config, old []byte) ([]byte, error) {
+	conf.Version = ConfigVersion
+
+	out, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil || !isTOML(cf) {
+		return out, err
 	}
-	defer f.Close()
 
-	buf, err := ioutil.ReadAll(f)
+	return jsonToTOML(out, old)
+}
+
+// ConvertConfig writes the configuration file of the library to the
+// file dst, in TOML format if its name ends in .toml and JSON
+// otherwise. With replace, the current configuration file is renamed
+// to a backup afterwards, so the default location picks up dst in its
+// place. Libraries not stored in a file result in ErrNoConfigFile.
+func (lib *Library) ConvertConfig(dst string, replace bool) error {
+	cf, err := lib.configFile()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// The config file is still empty.
-	if len(buf) == 0 {
-		return pods, nil
+	if dst, err = filepath.Abs(dst); err != nil {
+		return err
 	}
 
-	if err := json.Unmarshal(buf, &pods); err != nil {
-		return nil, err
+	if dst == cf {
+		return fmt.Errorf("%w: %s is the configuration file", ErrConfigExists, dst)
+	}
+
+	if fileExists(dst) {
+		return fmt.Errorf("%w: %s", ErrConfigExists, dst)
+	}
+
+	unlock, err := lockFile(cf)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	buf, err := readConfFile(cf)
+	if err != nil {
+		return err
+	}
+
+	conf, _, err := decodeConfigFile(cf, buf)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cf, err)
+	}
+
+	var old []byte
+	if isTOML(cf) {
+		old = buf
+	}
+
+	out, err := encodeConfig(dst, conf, old)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(dst, out, 0644); err != nil {
+		return err
+	}
+
+	if !replace {
+		return nil
+	}
+
+	return os.Rename(cf, cf+".bak")
+}
+
+// ConfigFile is the path of the configuration file. An empty value
+// selects the one of Profile.
+var ConfigFile string
+
+// Profile names a separate configuration file with its own podcasts
+// and settings in the user's config directory. An empty value selects
+// the default configuration file.
+var Profile string
+
+var validProfile = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// confFile returns the storage location of gopodcrab's configuration
+// file. Unless set by ConfigFile, it is in the user's default config
+// directory, the exact location of which is OS dependent. It is
+// gopodgrab/gopodgrab.json there, or gopodgrab/profiles/<name>.json
+// for a Profile. A .toml file by the same name takes precedence.
+func confFile() (string, error) {
+	if ConfigFile != "" {
+		return filepath.Abs(ConfigFile)
+	}
+
+	if Profile != "" && !validProfile.MatchString(Profile) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidProfile, Profile)
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("locating configuration file: %w", err)
+	}
+
+	base := filepath.Join(dir, "gopodgrab", "gopodgrab")
+	if Profile != "" {
+		base = filepath.Join(dir, "gopodgrab", "profiles", Profile)
+	}
+
+	if fileExists(base + ".toml") {
+		return base + ".toml", nil
+	}
+
+	return base + ".json", nil
+}
+
+// readConfFile reads the configuration file at cf. If the file doesn't
+// exist, it is created empty.
+func readConfFile(cf string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(cf)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, createConfFile(cf)
 	}
 
-	return pods, nil
+	return buf, err
 }
 
 // createConfFile creates an empty config file at location cf.
@@ -108,7 +373,8 @@ func createConfFile(cf string) error {
 		return err
 	}
 
-	f, err := os.Create(cf)
+	// Not truncating, as another process may have written it meanwhile.
+	f, err := os.OpenFile(cf, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
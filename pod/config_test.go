@@ -0,0 +1,155 @@
+package pod
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// tempConfig points the configuration file to a temporary directory
+// for the duration of the test.
+func tempConfig(t *testing.T) {
+	t.Helper()
+
+	ConfigFile = filepath.Join(t.TempDir(), "gopodgrab.json")
+	t.Cleanup(func() { ConfigFile = "" })
+}
+
+func TestSaveConcurrently(t *testing.T) {
+	tempConfig(t)
+
+	const n = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			lib, err := OpenLibrary()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			p := &Podcast{Name: fmt.Sprintf("pod%d", i)}
+			if err := lib.Add(p); err != nil {
+				errs <- err
+				return
+			}
+
+			errs <- p.Save()
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lib, err := OpenLibrary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lib.Podcasts()) != n {
+		t.Errorf("got %d podcasts, expected %d", len(lib.Podcasts()), n)
+	}
+
+	// Another library adding the same podcast meanwhile.
+	other, err := OpenLibrary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Podcast{Name: "late"}
+	if err := other.Add(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lib.Add(&Podcast{Name: "late"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lib.Save(lib.pods["late"]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := other.Save(p); !errors.Is(err, ErrPodExists) {
+		t.Errorf("expected ErrPodExists, got %v", err)
+	}
+
+	cf, err := confFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fileExists(cf + ".bak") {
+		t.Error("no backup of the configuration file")
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		version int
+		pods    int
+		err     error
+	}{
+		"empty":         {in: "", version: ConfigVersion},
+		"version 1":     {in: `{"foo": {"name": "foo"}, "bar": {"name": "bar"}}`, version: 1, pods: 2},
+		"pod named v":   {in: `{"version": {"name": "version"}}`, version: 1, pods: 1},
+		"version 2":     {in: `{"version": 2, "settings": {"reserve": "2GB"}, "podcasts": {"foo": {"name": "foo"}}}`, version: 2, pods: 1},
+		"newer version": {in: `{"version": 99, "podcasts": {}}`, version: 99, err: ErrConfigVersion},
+	}
+
+	for name, tc := range tests {
+		conf, version, err := decodeConfig([]byte(tc.in))
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: got error %v, expected %v", name, err, tc.err)
+			continue
+		}
+
+		if version != tc.version {
+			t.Errorf("%s: got version %d, expected %d", name, version, tc.version)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if conf.Version != ConfigVersion || conf.Settings == nil || len(conf.Podcasts) != tc.pods {
+			t.Errorf("%s: unexpected config %+v", name, conf)
+		}
+	}
+}
+
+func TestMigrateV2(t *testing.T) {
+	in := `{"version": 2, "settings": {"filename_template": "{{.Title}}"}, "podcasts": {"foo": {
+		"name": "foo", "filename_template": "{{.Number}}{{.Ext}}", "retention": {"keep_last": 3}, "tag_files": true}}}`
+
+	conf, _, err := decodeConfig([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := conf.Podcasts["foo"].Settings
+
+	if s.FilenameTemplate != "{{.Number}}{{.Ext}}" || s.Retention == nil || s.Retention.KeepLastCount() != 3 ||
+		s.TagFiles == nil || !*s.TagFiles {
+		t.Errorf("podcast settings not migrated: %+v", s)
+	}
+
+	if conf.Settings.FilenameTemplate != "{{.Title}}" {
+		t.Errorf("global filename template lost: %+v", conf.Settings)
+	}
+}
@@ -0,0 +1,465 @@
+package pod
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// configAliases are alternative names of configuration keys.
+var configAliases = map[string]string{
+	"storage": "local_store",
+}
+
+// GetConfig returns the value of the configuration key, a dotted path
+// like settings.reserve or podcasts.foocast.settings.max_episodes, from
+// the store of the library. Tables are returned as maps. A key of the
+// schema without a value results in ErrKeyNotSet.
+func (lib *Library) GetConfig(key string) (interface{}, error) {
+	conf, err := lib.store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	parts, _, err := configKey(conf, key)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := configTree(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{} = tree
+	for _, p := range parts {
+		m, _ := v.(map[string]interface{})
+
+		var ok bool
+		if v, ok = m[p]; !ok || v == nil {
+			return nil, fmt.Errorf("%w: %s", ErrKeyNotSet, key)
+		}
+	}
+
+	return v, nil
+}
+
+// SetConfig sets the configuration key to value in the store of the
+// library. Values of string keys are taken as they are, other values
+// are parsed as JSON, e.g. 5, true or ["foo-1"]. The changed
+// configuration is validated before it is saved. The library itself is
+// not changed until Reload.
+func (lib *Library) SetConfig(key, value string) error {
+	return lib.store.Update(func(conf *Config) error {
+		parts, typ, err := configKey(conf, key)
+		if err != nil {
+			return err
+		}
+
+		if parts[0] == "version" {
+			return fmt.Errorf("%w: the version cannot be set", ErrInvalidSetting)
+		}
+
+		var v interface{} = value
+		if typ.Kind() != reflect.String {
+			dec := json.NewDecoder(strings.NewReader(value))
+			dec.UseNumber()
+
+			if err := dec.Decode(&v); err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrInvalidSetting, key, err)
+			}
+		}
+
+		return changeTree(conf, func(tree map[string]interface{}) {
+			for _, p := range parts[:len(parts)-1] {
+				m, ok := tree[p].(map[string]interface{})
+				if !ok {
+					m = make(map[string]interface{})
+					tree[p] = m
+				}
+
+				tree = m
+			}
+
+			tree[parts[len(parts)-1]] = v
+		})
+	})
+}
+
+// UnsetConfig removes the value of the configuration key from the store
+// of the library. The changed configuration is validated before it is
+// saved. The library itself is not changed until Reload.
+func (lib *Library) UnsetConfig(key string) error {
+	return lib.store.Update(func(conf *Config) error {
+		parts, _, err := configKey(conf, key)
+		if err != nil {
+			return err
+		}
+
+		if parts[0] == "version" || parts[0] == "podcasts" && len(parts) <= 2 {
+			return fmt.Errorf("%w: %s cannot be unset", ErrInvalidSetting, key)
+		}
+
+		return changeTree(conf, func(tree map[string]interface{}) {
+			for _, p := range parts[:len(parts)-1] {
+				if tree, _ = tree[p].(map[string]interface{}); tree == nil {
+					return
+				}
+			}
+
+			delete(tree, parts[len(parts)-1])
+		})
+	})
+}
+
+// EditConfig lets edit change a copy of the configuration file of the
+// library at the path passed to it. The copy replaces the configuration
+// file if it is valid and the file was not changed by someone else
+// meanwhile. An invalid copy is edited again if retry approves. It
+// reports whether the configuration file was changed. The file is not
+// loaded before, so a broken one can be repaired. Libraries not stored
+// in a file result in ErrNoConfigFile.
+func (lib *Library) EditConfig(edit func(path string) error, retry func(err error) bool) (bool, error) {
+	cf, err := lib.configFile()
+	if err != nil {
+		return false, err
+	}
+
+	buf, err := readConfFile(cf)
+	if err != nil {
+		return false, err
+	}
+
+	start := buf
+	if len(buf) == 0 {
+		conf := &Config{}
+		conf.init()
+
+		if start, err = encodeConfig(cf, conf, nil); err != nil {
+			return false, err
+		}
+	}
+
+	f, err := ioutil.TempFile("", "gopodgrab-*"+filepath.Ext(cf))
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(start); err != nil {
+		f.Close()
+		return false, err
+	}
+
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+
+	var edited []byte
+	var conf *Config
+	var version int
+
+	for {
+		if err := edit(f.Name()); err != nil {
+			return false, err
+		}
+
+		if edited, err = ioutil.ReadFile(f.Name()); err != nil {
+			return false, err
+		}
+
+		if bytes.Equal(edited, start) {
+			return false, nil
+		}
+
+		if conf, version, err = checkConfig(cf, edited); err == nil {
+			break
+		}
+
+		if !retry(err) {
+			return false, err
+		}
+	}
+
+	// Files of older versions are saved in the current one.
+	if version < ConfigVersion {
+		if edited, err = encodeConfig(cf, conf, edited); err != nil {
+			return false, err
+		}
+	}
+
+	unlock, err := lockFile(cf)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	cur, err := readConfFile(cf)
+	if err != nil {
+		return false, err
+	}
+
+	if !bytes.Equal(cur, buf) {
+		return false, ErrConfigChanged
+	}
+
+	_, version, err = decodeConfigFile(cf, cur)
+	if err != nil {
+		version = ConfigVersion
+	}
+
+	return true, writeConfig(cf, cur, version, edited)
+}
+
+// Validate checks the configuration for errors.
+func (conf *Config) Validate() error {
+	if err := conf.Settings.Validate(); err != nil {
+		return fmt.Errorf("settings: %w", err)
+	}
+
+	names := make([]string, 0, len(conf.Podcasts))
+	for name := range conf.Podcasts {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := conf.Podcasts[name].validate(name); err != nil {
+			return fmt.Errorf("podcast %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks the global settings for errors.
+func (s *Settings) Validate() error {
+	if err := s.PodSettings.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := ParseSize(s.Reserve); err != nil {
+		return fmt.Errorf("%w: reserve: %v", ErrInvalidSetting, err)
+	}
+
+	return nil
+}
+
+// validate checks the podcast, configured under name, for errors.
+func (pod *Podcast) validate(name string) error {
+	if pod == nil {
+		return fmt.Errorf("%w: empty podcast", ErrInvalidSetting)
+	}
+
+	if name == "" {
+		return fmt.Errorf("%w: empty name", ErrInvalidSetting)
+	}
+
+	if pod.Name != name {
+		return fmt.Errorf("%w: name %q differs from the key %q", ErrInvalidSetting, pod.Name, name)
+	}
+
+	if name == ReservedPodName {
+		return ErrReservedName
+	}
+
+	if u, err := url.Parse(pod.FeedURL); err != nil || u.Scheme == "" {
+		return fmt.Errorf("%w: feed URL %q", ErrInvalidSetting, pod.FeedURL)
+	}
+
+	if pod.LocalStore == "" {
+		return fmt.Errorf("%w: no storage directory", ErrInvalidSetting)
+	}
+
+	return pod.Settings.Validate()
+}
+
+// checkConfig decodes the content buf of the configuration file cf
+// strictly, rejecting unknown keys, and validates it. It returns the
+// version found.
+func checkConfig(cf string, buf []byte) (*Config, int, error) {
+	if isTOML(cf) {
+		var err error
+		if buf, err = tomlToJSON(buf); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	buf, version, err := migrateConfig(buf)
+	if err != nil {
+		return nil, version, err
+	}
+
+	conf := &Config{Version: ConfigVersion}
+
+	if len(bytes.TrimSpace(buf)) > 0 {
+		if conf, err = decodeStrict(buf); err != nil {
+			return nil, version, err
+		}
+	}
+
+	conf.init()
+
+	return conf, version, conf.Validate()
+}
+
+// decodeStrict decodes the configuration buf in JSON format, rejecting
+// unknown keys and values of the wrong type.
+func decodeStrict(buf []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+
+	conf := &Config{Version: ConfigVersion}
+	if err := dec.Decode(conf); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("%w: %s must be of type %s", ErrInvalidSetting, typeErr.Field, typeErr.Type)
+		}
+
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSetting, err)
+	}
+
+	return conf, nil
+}
+
+// changeTree lets change modify conf as a tree of maps and values, as
+// decoded from JSON. The result is validated and replaces conf.
+func changeTree(conf *Config, change func(tree map[string]interface{})) error {
+	tree, err := configTree(conf)
+	if err != nil {
+		return err
+	}
+
+	change(tree)
+
+	buf, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+
+	changed, err := decodeStrict(buf)
+	if err != nil {
+		return err
+	}
+
+	changed.init()
+
+	if err := changed.Validate(); err != nil {
+		return err
+	}
+
+	*conf = *changed
+
+	return nil
+}
+
+// configTree returns conf as a tree of maps and values, as decoded
+// from JSON.
+func configTree(conf *Config) (map[string]interface{}, error) {
+	buf, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+
+	var tree map[string]interface{}
+	err = dec.Decode(&tree)
+
+	return tree, err
+}
+
+// configKey splits the dotted configuration key into its parts and
+// returns the type of its value. Podcast names may contain dots, the
+// longest name of a podcast in conf matching the key is taken.
+func configKey(conf *Config, key string) ([]string, reflect.Type, error) {
+	parts := strings.Split(key, ".")
+
+	if parts[0] == "podcasts" && len(parts) > 1 {
+		rest := strings.TrimPrefix(key, "podcasts.")
+
+		name := ""
+		for n := range conf.Podcasts {
+			if (rest == n || strings.HasPrefix(rest, n+".")) && len(n) > len(name) {
+				name = n
+			}
+		}
+
+		if name == "" {
+			return nil, nil, fmt.Errorf("%w: %s", ErrNoEntry, parts[1])
+		}
+
+		parts = []string{"podcasts", name}
+		if rest != name {
+			parts = append(parts, strings.Split(rest[len(name)+1:], ".")...)
+		}
+	}
+
+	t := reflect.TypeOf(Config{})
+
+	for i, p := range parts {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Map:
+			t = t.Elem()
+			continue
+		case reflect.Struct:
+			f, ok := jsonField(t, p)
+			if !ok {
+				if alias, isAlias := configAliases[p]; isAlias {
+					f, ok = jsonField(t, alias)
+					parts[i] = alias
+				}
+			}
+
+			if ok {
+				t = f.Type
+				continue
+			}
+		}
+
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownKey, key)
+	}
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return parts, t, nil
+}
+
+// jsonField returns the field of the struct type t encoded as name in
+// JSON, looking into embedded structs.
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+
+		if f.Anonymous && tag == "" {
+			if ef, ok := jsonField(f.Type, name); ok {
+				return ef, true
+			}
+
+			continue
+		}
+
+		if tag == name {
+			return f, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,76 @@
+package pod
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSetConfig(t *testing.T) {
+	lib, err := NewLibrary(NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Podcast{Name: "foo.cast", FeedURL: "http://example.com/feed.xml", LocalStore: "/tmp/foocast"}
+	if err := lib.Add(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		key, value string
+		err        error
+	}{
+		"global string":        {key: "settings.reserve", value: "5GB"},
+		"podcast alias":        {key: "podcasts.foo.cast.storage", value: "/tmp/bar"},
+		"podcast number":       {key: "podcasts.foo.cast.settings.max_episodes", value: "3"},
+		"new table":            {key: "podcasts.foo.cast.settings.retention.pinned", value: `["foo-1"]`},
+		"unknown key":          {key: "settings.bogus", value: "1", err: ErrUnknownKey},
+		"unknown podcast":      {key: "podcasts.bar.name", value: "bar", err: ErrNoEntry},
+		"wrong type":           {key: "settings.tag_files", value: `"yes"`, err: ErrInvalidSetting},
+		"invalid value":        {key: "settings.download_order", value: "sideways", err: ErrInvalidSetting},
+		"invalid size":         {key: "settings.reserve", value: "lots", err: ErrInvalidSetting},
+		"failing template":     {key: "settings.filename_template", value: "{{.PubDate 1}}", err: ErrInvalidSetting},
+		"mismatching name":     {key: "podcasts.foo.cast.name", value: "bar", err: ErrInvalidSetting},
+		"version not settable": {key: "version", value: "4", err: ErrInvalidSetting},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := lib.SetConfig(tc.key, tc.value); !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	for key, want := range map[string]string{
+		"settings.reserve":                        "5GB",
+		"podcasts.foo.cast.local_store":           "/tmp/bar",
+		"podcasts.foo.cast.settings.max_episodes": "3",
+	} {
+		v, err := lib.GetConfig(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fmt.Sprint(v) != want {
+			t.Errorf("%s: got %v, expected %s", key, v, want)
+		}
+	}
+
+	if err := lib.UnsetConfig("podcasts.foo.cast.settings.max_episodes"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := lib.GetConfig("podcasts.foo.cast.settings.max_episodes"); !errors.Is(err, ErrKeyNotSet) {
+		t.Errorf("expected ErrKeyNotSet, got %v", err)
+	}
+
+	if err := lib.UnsetConfig("podcasts.foo.cast"); !errors.Is(err, ErrInvalidSetting) {
+		t.Errorf("podcast unset: expected ErrInvalidSetting, got %v", err)
+	}
+}
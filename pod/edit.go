@@ -0,0 +1,164 @@
+package pod
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Edit lets change modify a copy of the podcast by that name, which then
+// replaces it. The changes are checked first: a new name must be free
+// and a new feed URL must serve a valid feed. A new local store is
+// moved to as Move does. On renaming, the podcast is stored under the
+// new name, and the stored feed is renamed with it. The stored feed of
+// a new feed URL is replaced, its history kept. If the configuration
+// cannot be changed, the local store and the stored feed are restored.
+func (lib *Library) Edit(name string, change func(p *Podcast) error) error {
+	pod, err := lib.Get(name)
+	if err != nil {
+		return err
+	}
+
+	edited := *pod
+	edited.lib = nil
+
+	if err := change(&edited); err != nil {
+		return err
+	}
+
+	if edited.Name != name && lib.Has(edited.Name) {
+		return fmt.Errorf("%w: %s", ErrPodExists, edited.Name)
+	}
+
+	if edited.LocalStore != pod.LocalStore {
+		if edited.LocalStore, err = lib.storagePath(name, edited.LocalStore, ""); err != nil {
+			return err
+		}
+	}
+
+	if err := edited.validate(edited.Name); err != nil {
+		return err
+	}
+
+	var feed []byte
+	if edited.FeedURL != pod.FeedURL {
+		if feed, err = fetchFeed(edited.FeedURL); err != nil {
+			return fmt.Errorf("%s: %w", edited.FeedURL, err)
+		}
+	}
+
+	oldStore := pod.LocalStore
+	if !sameDir(edited.LocalStore, oldStore) {
+		if err := pod.Move(edited.LocalStore); err != nil {
+			return err
+		}
+	}
+
+	edited.LocalStore = pod.LocalStore
+
+	// The stored feed is replaced, or renamed along with the podcast,
+	// before the configuration is changed, so both can be undone.
+	var oldFeed []byte
+	if fileExists(pod.FeedFile()) && (feed != nil || edited.Name != name) {
+		if oldFeed, err = pod.readFeedFile(); err != nil {
+			return lib.undoEdit(pod, oldStore, nil, err)
+		}
+
+		if feed == nil {
+			feed = oldFeed
+		}
+	}
+
+	if feed != nil {
+		if err := edited.writeFeedFile(bytes.NewReader(feed)); err != nil {
+			return lib.undoEdit(pod, oldStore, oldFeed, err)
+		}
+	}
+
+	err = lib.store.Update(func(conf *Config) error {
+		if edited.Name != name {
+			if _, ok := conf.Podcasts[edited.Name]; ok {
+				return fmt.Errorf("%w: %s", ErrPodExists, edited.Name)
+			}
+
+			delete(conf.Podcasts, name)
+		}
+
+		conf.Podcasts[edited.Name] = &edited
+
+		return nil
+	})
+	if err != nil {
+		return lib.undoEdit(pod, oldStore, oldFeed, err)
+	}
+
+	*pod = edited
+	pod.lib = lib
+
+	if name != pod.Name {
+		delete(lib.pods, name)
+		lib.pods[pod.Name] = pod
+	}
+
+	if feed != nil && !bytes.Equal(feed, oldFeed) {
+		if err := pod.addSnapshot(time.Now()); err != nil {
+			return fmt.Errorf("%s changed, but its feed history is not: %w", pod.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// undoEdit restores the stored feed of the podcast to oldFeed, if
+// given, and moves its local store back to oldStore after an edit
+// failed with err. It returns err, along with any failure to undo.
+func (lib *Library) undoEdit(pod *Podcast, oldStore string, oldFeed []byte, err error) error {
+	if oldFeed != nil {
+		if ferr := pod.writeFeedFile(bytes.NewReader(oldFeed)); ferr != nil {
+			return fmt.Errorf("%w, restoring the stored feed failed too: %v", err, ferr)
+		}
+	}
+
+	if !sameDir(pod.LocalStore, oldStore) {
+		if merr := pod.Move(oldStore); merr != nil {
+			return fmt.Errorf("%w, moving the store back to %s failed too: %v", err, oldStore, merr)
+		}
+	}
+
+	return err
+}
+
+// fetchFeed retrieves the feed at feedURL and makes sure it is a valid
+// feed. Feeds larger than MaxFeedSize are rejected.
+func fetchFeed(feedURL string) ([]byte, error) {
+	if err := checkURL(feedURL); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Get(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := limitedCopy(&buf, resp.Body, MaxFeedSize, ErrFeedTooLarge); err != nil {
+		return nil, err
+	}
+
+	ch, eps, err := parseFeedChannel(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFeed, err)
+	}
+
+	if ch.Title == "" && len(eps) == 0 {
+		return nil, fmt.Errorf("%w: no channel title or episodes", ErrInvalidFeed)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,153 @@
+package pod
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestEdit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page.html":
+			fmt.Fprint(w, `<html><body>Not a feed</body></html>`)
+		case "/old.xml", "/new.xml":
+			fmt.Fprintf(w, `<rss><channel><title>Foocast %s</title></channel></rss>`, r.URL.Path)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+
+	lib, err := NewLibrary(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foo := &Podcast{Name: "foo", FeedURL: srv.URL + "/old.xml", LocalStore: filepath.Join(t.TempDir(), "foo")}
+	bar := &Podcast{Name: "bar", FeedURL: srv.URL + "/old.xml", LocalStore: filepath.Join(t.TempDir(), "bar")}
+
+	for _, p := range []*Podcast{foo, bar} {
+		if err := lib.Add(p); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.RefreshFeed(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := lib.Save(foo, bar); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		change func(p *Podcast)
+		err    error
+	}{
+		"name taken":       {change: func(p *Podcast) { p.Name = "bar" }, err: ErrPodExists},
+		"reserved name":    {change: func(p *Podcast) { p.Name = ReservedPodName }, err: ErrReservedName},
+		"feed not found":   {change: func(p *Podcast) { p.FeedURL = srv.URL + "/gone.xml" }, err: ErrHTTPStatus},
+		"not a feed":       {change: func(p *Podcast) { p.FeedURL = srv.URL + "/page.html" }, err: ErrInvalidFeed},
+		"invalid settings": {change: func(p *Podcast) { p.Settings.DownloadOrder = "random" }, err: ErrInvalidSetting},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := lib.Edit("foo", func(p *Podcast) error { tc.change(p); return nil })
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+
+			if foo.Name != "foo" || foo.FeedURL != srv.URL+"/old.xml" || foo.Settings.DownloadOrder != "" {
+				t.Fatalf("podcast changed: %+v", foo)
+			}
+		})
+	}
+
+	three := 3
+	err = lib.Edit("foo", func(p *Podcast) error {
+		p.Name = "foocast"
+		p.FeedURL = srv.URL + "/new.xml"
+		p.Settings.MaxEpisodes = &three
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lib.Has("foo") || !lib.Has("foocast") || foo.Name != "foocast" {
+		t.Errorf("podcast not renamed in the library")
+	}
+
+	conf, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := conf.Podcasts["foocast"]; p == nil || conf.Podcasts["foo"] != nil || p.FeedURL != srv.URL+"/new.xml" || *p.Settings.MaxEpisodes != 3 {
+		t.Errorf("podcast not renamed in the store: %+v", conf.Podcasts)
+	}
+
+	arc, err := zip.OpenReader(foo.FeedFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer arc.Close()
+
+	if len(arc.File) != 1 || arc.File[0].Name != "foocast" {
+		t.Errorf("stored feed not renamed")
+	}
+
+	ch, _, err := foo.readFeedChannel()
+	if err != nil || ch.Title != "Foocast /new.xml" {
+		t.Errorf("stored feed not replaced: %+v, %v", ch, err)
+	}
+
+	snaps, err := foo.Snapshots()
+	if err != nil || len(snaps) == 0 || !bytes.Contains(snaps[len(snaps)-1].data, []byte("/new.xml")) {
+		t.Errorf("new feed not added to the history: %v", err)
+	}
+
+	// Another process takes the new name meanwhile, the store and the
+	// stored feed are restored.
+	err = store.Update(func(conf *Config) error {
+		conf.Podcasts["taken"] = &Podcast{Name: "taken", FeedURL: srv.URL + "/old.xml", LocalStore: t.TempDir()}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldStore := foo.LocalStore
+	newStore := filepath.Join(t.TempDir(), "taken")
+
+	err = lib.Edit("foocast", func(p *Podcast) error {
+		p.Name = "taken"
+		p.FeedURL = srv.URL + "/old.xml"
+		p.LocalStore = newStore
+		return nil
+	})
+	if !errors.Is(err, ErrPodExists) {
+		t.Fatalf("expected %v, got %v", ErrPodExists, err)
+	}
+
+	if foo.Name != "foocast" || foo.LocalStore != oldStore || dirExists(newStore) {
+		t.Errorf("store not moved back: %+v", foo)
+	}
+
+	if conf, err = store.Load(); err != nil || conf.Podcasts["foocast"].LocalStore != oldStore {
+		t.Errorf("moved store left in the configuration: %v", err)
+	}
+
+	if ch, _, err := foo.readFeedChannel(); err != nil || ch.Title != "Foocast /new.xml" {
+		t.Errorf("stored feed not restored: %+v, %v", ch, err)
+	}
+}
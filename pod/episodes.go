@@ -0,0 +1,208 @@
+package pod
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EpisodeStatus tells how an episode of the feed relates to the local
+// store.
+type EpisodeStatus int
+
+const (
+	// StatusNew episodes are not downloaded yet.
+	StatusNew EpisodeStatus = iota
+	// StatusDownloaded episodes have their file in the local store.
+	StatusDownloaded
+	// StatusIgnored episodes are not downloaded by update: they were
+	// pruned by retention rules, have no enclosure or repeat an earlier
+	// item.
+	StatusIgnored
+	// StatusMissing episodes were downloaded, but their file is gone
+	// from the local store.
+	StatusMissing
+)
+
+var statusNames = []string{"new", "downloaded", "ignored", "missing"}
+
+func (s EpisodeStatus) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return fmt.Sprintf("EpisodeStatus(%d)", int(s))
+	}
+
+	return statusNames[s]
+}
+
+// ParseEpisodeStatus parses the name of an episode status as returned
+// by its String method.
+func ParseEpisodeStatus(s string) (EpisodeStatus, error) {
+	for i, name := range statusNames {
+		if strings.EqualFold(s, name) {
+			return EpisodeStatus(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %q, use one of %s", ErrInvalidStatus, s, strings.Join(statusNames, ", "))
+}
+
+// FeedItem is an episode of the stored feed with its status in the
+// local store.
+type FeedItem struct {
+	Index     int           // Position in the feed, counting from 1
+	Episode   *Episode      // The episode as in the feed
+	Status    EpisodeStatus // Status of the episode in the local store
+	File      string        // Name of the episode file in the local store, empty for ignored episodes
+	Published time.Time     // Publishing date, zero if the feed lacks it
+	Size      int64         // Size of the file if downloaded, the declared enclosure length otherwise
+}
+
+// FeedItems returns every item of the stored feed in feed order, along
+// with its status in the local store. Episodes are matched against the
+// store index as update does, so the episodes listed as new are the
+// ones update would download. The local store is left unchanged. A
+// feed not fetched yet results in ErrNoFeed.
+func (pod *Podcast) FeedItems() ([]*FeedItem, error) {
+	if !fileExists(pod.FeedFile()) {
+		return nil, fmt.Errorf("%w: %s", ErrNoFeed, pod.Name)
+	}
+
+	feedEpis, idx, newEpis, _, err := pod.matchStore()
+	if err != nil {
+		return nil, err
+	}
+
+	isNew := make(map[*Episode]bool, len(newEpis))
+	for _, e := range newEpis {
+		isNew[e] = true
+	}
+
+	items := make([]*FeedItem, 0, len(feedEpis))
+	seen := make(map[string]bool, len(feedEpis))
+
+	for i, e := range feedEpis {
+		it := &FeedItem{Index: i + 1, Episode: e, Status: StatusIgnored, Published: pubDate(e)}
+		items = append(items, it)
+
+		if e.File == nil || seen[episodeKey(e)] {
+			continue
+		}
+		seen[episodeKey(e)] = true
+
+		it.Size = e.File.Size
+
+		if isNew[e] {
+			it.Status = StatusNew
+			it.File = e.file
+			continue
+		}
+
+		se, ok := idx.Episodes[episodeKey(e)]
+		if !ok {
+			continue
+		}
+
+		it.File = se.File
+
+		if !se.Pruned.IsZero() {
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(pod.LocalStore, se.File))
+		if err != nil {
+			it.Status = StatusMissing
+			continue
+		}
+
+		it.Status = StatusDownloaded
+		it.Size = info.Size()
+	}
+
+	return items, nil
+}
+
+// ItemFilter selects feed items. Fields left empty don't apply.
+type ItemFilter struct {
+	Statuses []EpisodeStatus // Statuses of the items to select
+	Since    time.Time       // Earliest publishing date
+	Until    time.Time       // Publishing date the items must precede
+	Title    *regexp.Regexp  // Expression the title must match
+}
+
+// Match reports whether the feed item passes the filter. Items without
+// a publishing date fail any date range.
+func (f *ItemFilter) Match(it *FeedItem) bool {
+	if len(f.Statuses) > 0 {
+		found := false
+		for _, s := range f.Statuses {
+			found = found || s == it.Status
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if !f.Since.IsZero() && (it.Published.IsZero() || it.Published.Before(f.Since)) {
+		return false
+	}
+
+	if !f.Until.IsZero() && (it.Published.IsZero() || !it.Published.Before(f.Until)) {
+		return false
+	}
+
+	return f.Title == nil || f.Title.MatchString(it.Episode.Title)
+}
+
+// Filter returns the items passing the filter, keeping their order.
+func (f *ItemFilter) Filter(items []*FeedItem) []*FeedItem {
+	var res []*FeedItem
+
+	for _, it := range items {
+		if f.Match(it) {
+			res = append(res, it)
+		}
+	}
+
+	return res
+}
+
+// itemOrders compares feed items by the keys they can be sorted by.
+var itemOrders = map[string]func(a, b *FeedItem) bool{
+	"index":    func(a, b *FeedItem) bool { return a.Index < b.Index },
+	"date":     func(a, b *FeedItem) bool { return a.Published.Before(b.Published) },
+	"title":    func(a, b *FeedItem) bool { return strings.ToLower(a.Episode.Title) < strings.ToLower(b.Episode.Title) },
+	"duration": func(a, b *FeedItem) bool { return a.Episode.Duration < b.Episode.Duration },
+	"size":     func(a, b *FeedItem) bool { return a.Size < b.Size },
+	"status":   func(a, b *FeedItem) bool { return a.Status < b.Status },
+}
+
+// SortItems sorts the feed items by key, one of index, date, title,
+// duration, size and status, in ascending order unless reverse is set.
+// Items of equal keys keep their order.
+func SortItems(items []*FeedItem, key string, reverse bool) error {
+	less, ok := itemOrders[strings.ToLower(key)]
+	if !ok {
+		keys := make([]string, 0, len(itemOrders))
+		for k := range itemOrders {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		return fmt.Errorf("%w: %q, use one of %s", ErrInvalidSortKey, key, strings.Join(keys, ", "))
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if reverse {
+			return less(items[j], items[i])
+		}
+
+		return less(items[i], items[j])
+	})
+
+	return nil
+}
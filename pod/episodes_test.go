@@ -0,0 +1,146 @@
+package pod
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFeedItems(t *testing.T) {
+	p := fixtureStore(t, "feed.xml", map[string]int{
+		"Bonus.mp3":  1000,
+		"Second.mp3": 2000,
+	})
+
+	items, err := p.FeedItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []EpisodeStatus{StatusNew, StatusDownloaded, StatusDownloaded}
+	for i, it := range items {
+		if it.Index != i+1 || it.Status != expected[i] {
+			t.Errorf("item %d: got index %d, status %v, but expected %v", i, it.Index, it.Status, expected[i])
+		}
+	}
+
+	if fileExists(p.indexFile()) {
+		t.Error("store index written by listing the feed items")
+	}
+
+	// Adopt the files like an update would, then drop one file by hand
+	// and prune the other.
+	if _, err := p.NewEpisodes(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(p.LocalStore, "Bonus.mp3")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Prune([]*Prunable{{File: "Second.mp3", key: "foo-2"}}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err = p.FeedItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []EpisodeStatus{StatusNew, StatusIgnored, StatusMissing}
+	for i, it := range items {
+		if it.Status != expected[i] {
+			t.Errorf("item %d: got status %v, but expected %v", i, it.Status, expected[i])
+		}
+	}
+
+	tests := map[string]struct {
+		filter   ItemFilter
+		expected []int
+	}{
+		"No filter": {filter: ItemFilter{}, expected: []int{1, 2, 3}},
+		"Status":    {filter: ItemFilter{Statuses: []EpisodeStatus{StatusNew, StatusMissing}}, expected: []int{1, 3}},
+		"Since":     {filter: ItemFilter{Since: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)}, expected: []int{1, 2}},
+		"Until":     {filter: ItemFilter{Until: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)}, expected: []int{3}},
+		"Title":     {filter: ItemFilter{Title: regexp.MustCompile("(?i)^bonus")}, expected: []int{1, 3}},
+	}
+
+	for name, test := range tests {
+		var res []int
+		for _, it := range test.filter.Filter(items) {
+			res = append(res, it.Index)
+		}
+
+		if !equalInts(res, test.expected) {
+			t.Errorf("%s: got %v, but expected %v", name, res, test.expected)
+		}
+	}
+}
+
+func TestFeedItemsWithoutFeed(t *testing.T) {
+	p := &Podcast{Name: "foocast", LocalStore: t.TempDir()}
+
+	if _, err := p.FeedItems(); !errors.Is(err, ErrNoFeed) {
+		t.Errorf("expected %v, got %v", ErrNoFeed, err)
+	}
+}
+
+func TestSortItems(t *testing.T) {
+	p := fixtureStore(t, "feed.xml", nil)
+
+	items, err := p.FeedItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		key      string
+		reverse  bool
+		expected []int
+	}{
+		"Date":          {key: "date", expected: []int{3, 2, 1}},
+		"Title":         {key: "title", expected: []int{1, 3, 2}},
+		"Title reverse": {key: "Title", reverse: true, expected: []int{2, 1, 3}},
+		"Index reverse": {key: "index", reverse: true, expected: []int{3, 2, 1}},
+	}
+
+	for name, test := range tests {
+		if err := SortItems(items, "index", false); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := SortItems(items, test.key, test.reverse); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		var res []int
+		for _, it := range items {
+			res = append(res, it.Index)
+		}
+
+		if !equalInts(res, test.expected) {
+			t.Errorf("%s: got %v, but expected %v", name, res, test.expected)
+		}
+	}
+
+	if err := SortItems(items, "rating", false); !errors.Is(err, ErrInvalidSortKey) {
+		t.Errorf("expected %v, got %v", ErrInvalidSortKey, err)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -3,8 +3,38 @@ package pod
 import "errors"
 
 var (
-	ErrPodExists    = errors.New("podcast by that name already exists")
-	ErrNoEntry      = errors.New("no podcast is managed by that name")
-	ErrReservedName = errors.New("the name " + ReservedPodName + " is reserved by gopodgrab")
-	ErrArchiveEmpty = errors.New("feed file zip archive empty")
+	ErrPodExists         = errors.New("podcast by that name already exists")
+	ErrNoEntry           = errors.New("no podcast is managed by that name")
+	ErrReservedName      = errors.New("the name " + ReservedPodName + " is reserved by gopodgrab")
+	ErrArchiveEmpty      = errors.New("feed file zip archive empty")
+	ErrSchemeNotAllowed  = errors.New("URL scheme not allowed")
+	ErrHTTPStatus        = errors.New("unexpected HTTP status")
+	ErrUnsafePath        = errors.New("path escapes the podcast storage")
+	ErrFeedTooLarge      = errors.New("feed exceeds size limit")
+	ErrFeedTooDeep       = errors.New("feed exceeds nesting limit")
+	ErrDownloadOverrun   = errors.New("download exceeds declared length")
+	ErrInvalidRetention  = errors.New("invalid retention rule")
+	ErrNoEpisode         = errors.New("no downloaded episode matches")
+	ErrNoSnapshot        = errors.New("no such feed snapshot")
+	ErrUnsupportedFormat = errors.New("unsupported file format")
+	ErrImageTooLarge     = errors.New("image exceeds size limit")
+	ErrFreeSpaceUnknown  = errors.New("free disk space cannot be determined")
+	ErrMoveTarget        = errors.New("cannot move the podcast storage there")
+	ErrMoveInProgress    = errors.New("an earlier move of the podcast storage is unfinished")
+	ErrConfigVersion     = errors.New("configuration file is of a newer gopodgrab version")
+	ErrInvalidProfile    = errors.New("invalid profile name, use letters, digits, - and _")
+	ErrInvalidSetting    = errors.New("invalid setting")
+	ErrNoStorage         = errors.New("no storage directory given and no library root set")
+	ErrConfigExists      = errors.New("configuration file exists already")
+	ErrUnknownKey        = errors.New("unknown configuration key")
+	ErrKeyNotSet         = errors.New("configuration key not set")
+	ErrConfigChanged     = errors.New("configuration file changed meanwhile")
+	ErrNoLibrary         = errors.New("podcast is not managed in a library")
+	ErrSharedStore       = errors.New("podcast storage is shared with another podcast")
+	ErrInvalidFeed       = errors.New("not a valid podcast feed")
+	ErrInvalidStatus     = errors.New("invalid episode status")
+	ErrInvalidSortKey    = errors.New("invalid sort key")
+	ErrNoFeed            = errors.New("feed not fetched yet, run update first")
+	ErrNoConfigFile      = errors.New("library is not stored in a configuration file")
+	ErrEmptyFilename     = errors.New("file name template yields an empty name")
 )
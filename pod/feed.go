@@ -2,10 +2,12 @@ package pod
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,12 +18,17 @@ func (p *podTime) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
 	if err != nil {
 		return err
 	}
-	data := string(tok.(xml.CharData))
-	t, err := time.Parse("Mon, 2 Jan 2006 15:04:05 -0700", data)
-	if err != nil {
-		return err
+	switch tok := tok.(type) {
+	case xml.EndElement:
+		// An empty element, like <pubDate/>, has no date.
+		return nil
+	case xml.CharData:
+		t, err := time.Parse("Mon, 2 Jan 2006 15:04:05 -0700", strings.TrimSpace(string(tok)))
+		if err != nil {
+			return err
+		}
+		*p = podTime(t)
 	}
-	*p = podTime(t)
 	err = dec.Skip()
 	return err
 }
@@ -55,13 +62,56 @@ func (f *podFile) String() string {
 	return fmt.Sprintf("URL: %s\nSize (bytes): %d\nEncoding: %s", f.URL, f.Size, f.Enc)
 }
 
+// podImage is the URL of an image, either given by an RSS <image>
+// element with an <url> child or by an <itunes:image href="...">.
+type podImage string
+
+func (i *podImage) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	for _, a := range start.Attr {
+		if a.Name.Local == "href" && a.Value != "" {
+			*i = podImage(strings.TrimSpace(a.Value))
+			return dec.Skip()
+		}
+	}
+
+	var img struct {
+		URL string `xml:"url"`
+	}
+
+	if err := dec.DecodeElement(&img, &start); err != nil {
+		return err
+	}
+
+	if u := strings.TrimSpace(img.URL); u != "" {
+		*i = podImage(u)
+	}
+
+	return nil
+}
+
+// Channel holds the metadata of a podcast feed.
+type Channel struct {
+	Title       string
+	Description string
+	Author      string
+	Image       string // URL of the podcast cover art
+}
+
 type Episode struct {
-	XMLName  xml.Name `xml:"item"`
-	Title    string   `xml:"title"`
-	PubDate  *podTime `xml:"pubDate"`
-	File     *podFile `xml:"enclosure"`
-	Duration int      `xml:"duration"`
-	Bytes    int64    `xml:"-"`
+	XMLName     xml.Name   `xml:"item"`
+	Title       string     `xml:"title"`
+	GUID        string     `xml:"guid"`
+	PubDate     *podTime   `xml:"pubDate"`
+	File        *podFile   `xml:"-"` // The enclosure to download, the first one unless another type is preferred
+	Enclosures  []*podFile `xml:"enclosure"`
+	Duration    int        `xml:"duration"`
+	Season      int        `xml:"season"`
+	Number      int        `xml:"episode"`
+	Description string     `xml:"description"`
+	Image       podImage   `xml:"image"`
+	Bytes       int64      `xml:"-"`
+
+	file string // Name of the episode file in the local store
 }
 
 func (e *Episode) String() string {
@@ -71,34 +121,105 @@ func (e *Episode) String() string {
 		time.Duration(e.Duration)*time.Second)
 }
 
+// parseFeed parses the episodes from feed r. Feeds nesting elements
+// deeper than maxXMLDepth are rejected.
 func parseFeed(r io.Reader) ([]*Episode, error) {
-	dec := xml.NewDecoder(r)
+	_, episodes, err := parseFeedChannel(r)
+	return episodes, err
+}
+
+// parseFeedChannel parses the channel metadata and the episodes
+// from feed r.
+func parseFeedChannel(r io.Reader) (*Channel, []*Episode, error) {
+	dec := xml.NewTokenDecoder(&depthLimiter{dec: xml.NewDecoder(r), max: maxXMLDepth})
 	var episodes []*Episode
+	channel := new(Channel)
+
+	// Names of the open elements, items are decoded as a whole.
+	var open []string
 
 	for {
 		tok, err := dec.Token()
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		switch el := tok.(type) {
+		case xml.EndElement:
+			if len(open) > 0 {
+				open = open[:len(open)-1]
+			}
 		case xml.StartElement:
 			if el.Name.Local != "item" {
+				if len(open) > 0 && open[len(open)-1] == "channel" {
+					if err := channel.decodeField(dec, &el); err != nil {
+						return nil, nil, err
+					}
+					continue
+				}
+
+				open = append(open, el.Name.Local)
 				continue
 			}
 
 			epi := new(Episode)
 			err := dec.DecodeElement(epi, &el)
-			if err != nil {
+			if errors.Is(err, ErrFeedTooDeep) {
+				return nil, nil, err
+			} else if err != nil {
 				log.Printf("failed to parse episode from feed: %v", err)
 			}
+
+			if len(epi.Enclosures) > 0 {
+				epi.File = epi.Enclosures[0]
+			}
 			episodes = append(episodes, epi)
 		}
 	}
 
-	return episodes, nil
+	return channel, episodes, nil
+}
+
+// decodeField decodes the channel child element start into c if it
+// holds metadata gopodgrab is interested in and skips it otherwise.
+func (c *Channel) decodeField(dec *xml.Decoder, start *xml.StartElement) error {
+	var text string
+
+	switch start.Name.Local {
+	case "title", "description", "author":
+		if err := dec.DecodeElement(&text, start); err != nil {
+			return err
+		}
+	case "image":
+		var img podImage
+		if err := dec.DecodeElement(&img, start); err != nil {
+			return err
+		}
+
+		// The iTunes image usually has the higher resolution.
+		if c.Image == "" || start.Name.Space != "" {
+			c.Image = string(img)
+		}
+
+		return nil
+	default:
+		return dec.Skip()
+	}
+
+	text = strings.TrimSpace(text)
+
+	switch {
+	case start.Name.Local == "title" && c.Title == "":
+		c.Title = text
+	case start.Name.Local == "description" && c.Description == "":
+		c.Description = text
+	case start.Name.Local == "author" && c.Author == "":
+		c.Author = text
+	}
+
+	return nil
 }
 
 var datafew = `
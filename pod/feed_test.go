@@ -0,0 +1,45 @@
+package pod
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFeedPubDate(t *testing.T) {
+	tests := map[string]struct {
+		pubDate  string
+		expected time.Time
+	}{
+		"Date":          {pubDate: "<pubDate> Sat, 2 Jan 2021 10:00:00 +0000 </pubDate>", expected: time.Date(2021, 1, 2, 10, 0, 0, 0, time.UTC)},
+		"Empty":         {pubDate: "<pubDate></pubDate>"},
+		"Self-closing":  {pubDate: "<pubDate/>"},
+		"Only comments": {pubDate: "<pubDate><!-- unknown --></pubDate>"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			feed := `<rss><channel>
+<item><title>First</title>` + tc.pubDate + `<enclosure url="https://example.com/1.mp3" length="1000"/></item>
+<item><title>Second</title><enclosure url="https://example.com/2.mp3" length="2000"/></item>
+</channel></rss>`
+
+			eps, err := parseFeed(strings.NewReader(feed))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(eps) != 2 || eps[0].Title != "First" || eps[1].Title != "Second" {
+				t.Fatalf("unexpected episodes %v", eps)
+			}
+
+			if eps[0].File == nil || eps[0].File.URL != "https://example.com/1.mp3" {
+				t.Errorf("enclosure after the publishing date lost: %v", eps[0].File)
+			}
+
+			if got := time.Time(*eps[0].PubDate); !got.Equal(tc.expected) {
+				t.Errorf("got publishing date %v, expected %v", got, tc.expected)
+			}
+		})
+	}
+}
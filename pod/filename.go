@@ -0,0 +1,287 @@
+package pod
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultFilenameTemplate names episode files after their title,
+	// which is what gopodgrab always did before templates existed.
+	DefaultFilenameTemplate = "{{.Title}}{{.Ext}}"
+
+	// maxFilenameLen is the maximum length in bytes of an episode file
+	// name including its extension. Most file systems allow 255 bytes,
+	// some room is left for suffixes added later on.
+	maxFilenameLen = 200
+)
+
+// FilenameTemplate is the global template for episode file names. It
+// is used for every podcast that doesn't define its own template, and
+// takes precedence over the filename_template of the global settings.
+// An empty value selects the setting, or DefaultFilenameTemplate.
+var FilenameTemplate string
+
+// validExt matches file extensions that are safe to take over from
+// an enclosure URL.
+var validExt = regexp.MustCompile(`^\.[A-Za-z0-9]{1,8}$`)
+
+// mimeExts maps the MIME types common in podcast feeds to their
+// extension. The system MIME tables are only consulted for types
+// not listed here, as they often yield rather exotic extensions.
+var mimeExts = map[string]string{
+	"audio/mpeg":      ".mp3",
+	"audio/mp3":       ".mp3",
+	"audio/x-mp3":     ".mp3",
+	"audio/mp4":       ".m4a",
+	"audio/x-m4a":     ".m4a",
+	"audio/m4a":       ".m4a",
+	"audio/aac":       ".m4a",
+	"audio/x-aac":     ".aac",
+	"audio/ogg":       ".ogg",
+	"audio/opus":      ".opus",
+	"audio/flac":      ".flac",
+	"audio/wav":       ".wav",
+	"audio/x-wav":     ".wav",
+	"video/mp4":       ".mp4",
+	"video/x-m4v":     ".m4v",
+	"video/quicktime": ".mov",
+	"application/pdf": ".pdf",
+}
+
+// reservedNames are device names which cannot be used as file names
+// on Windows, regardless of their extension.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// filenameData is the data an episode file name template is executed
+// with.
+type filenameData struct {
+	Title   string // Title of the episode
+	GUID    string // Globally unique ID of the episode as given by the feed
+	Season  int    // Season number, zero if not in the feed
+	Number  int    // Episode number, zero if not in the feed
+	Podcast string // Name under which the podcast is managed
+	Ext     string // File extension including the leading dot
+
+	pubDate time.Time
+}
+
+// PubDate formats the publishing date of the episode with the
+// given layout as understood by time.Format.
+func (d filenameData) PubDate(layout string) string {
+	if d.pubDate.IsZero() {
+		return ""
+	}
+
+	return d.pubDate.Format(layout)
+}
+
+// ParseFilenameTemplate parses a template for episode file names. See
+// ValidateFilenameTemplate for checking templates before they are
+// stored.
+func ParseFilenameTemplate(text string) (*template.Template, error) {
+	return template.New("filename").Option("missingkey=error").Parse(text)
+}
+
+// sampleFilenameData is a made up episode to try templates on.
+var sampleFilenameData = filenameData{
+	Title:   "Sample Episode",
+	GUID:    "sample-1",
+	Season:  1,
+	Number:  1,
+	Podcast: "sample",
+	Ext:     ".mp3",
+	pubDate: time.Date(2021, 1, 2, 10, 0, 0, 0, time.UTC),
+}
+
+// ValidateFilenameTemplate checks a template for episode file names
+// before it is stored. Besides parsing it, the template is executed
+// for a sample episode, so unknown fields or wrong arguments are found
+// now rather than on the next update. Templates yielding an empty name
+// result in ErrEmptyFilename. An empty text, selecting the global
+// template, is valid.
+func ValidateFilenameTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+
+	tmpl, err := ParseFilenameTemplate(text)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, sampleFilenameData); err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(b.String()) == "" {
+		return fmt.Errorf("%w: %s", ErrEmptyFilename, text)
+	}
+
+	return nil
+}
+
+// filenameTemplate returns the template in effect for the podcast. The
+// podcast's own template comes first, then the global FilenameTemplate
+// and the one of the global defaults.
+func (pod *Podcast) filenameTemplate() string {
+	if pod.Settings.FilenameTemplate != "" {
+		return pod.Settings.FilenameTemplate
+	}
+
+	if FilenameTemplate != "" {
+		return FilenameTemplate
+	}
+
+	if def := pod.defaults(); def.FilenameTemplate != "" {
+		return def.FilenameTemplate
+	}
+
+	return DefaultFilenameTemplate
+}
+
+// episodeFilename returns the file name, without any directory, under
+// which episode e is stored. The name is produced by the podcast's
+// file name template and sanitized to be valid on all major platforms.
+func (pod *Podcast) episodeFilename(e *Episode) (string, error) {
+	tmpl, err := ParseFilenameTemplate(pod.filenameTemplate())
+	if err != nil {
+		return "", err
+	}
+
+	data := filenameData{
+		Title:   e.Title,
+		GUID:    e.GUID,
+		Season:  e.Season,
+		Number:  e.Number,
+		Podcast: pod.Name,
+		Ext:     episodeExt(e.File),
+	}
+
+	if e.PubDate != nil {
+		data.pubDate = time.Time(*e.PubDate)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return sanitizeFilename(b.String()), nil
+}
+
+// episodeExt works out the file extension of an enclosure. The
+// extension in the URL path is preferred, the MIME type is used if
+// the URL doesn't have a usable one. Returns an empty string if
+// neither helps.
+func episodeExt(f *podFile) string {
+	if f == nil {
+		return ""
+	}
+
+	if u, err := url.Parse(f.URL); err == nil {
+		if ext := filepath.Ext(u.Path); validExt.MatchString(ext) {
+			return strings.ToLower(ext)
+		}
+	}
+
+	mt, _, err := mime.ParseMediaType(f.Enc)
+	if err != nil {
+		return ""
+	}
+
+	if ext, ok := mimeExts[mt]; ok {
+		return ext
+	}
+
+	exts, err := mime.ExtensionsByType(mt)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+
+	return exts[0]
+}
+
+// sanitizeFilename turns name into a file name that is valid on
+// Linux, macOS and Windows. Path separators and other reserved
+// characters are replaced, whitespace is collapsed, leading and
+// trailing dots and spaces are removed and the name is cut to
+// maxFilenameLen bytes, keeping the extension intact.
+func sanitizeFilename(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+
+	var b strings.Builder
+	space := false
+
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case r < 0x20 || r == 0x7f:
+			continue
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			r = '_'
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteRune(' ')
+		}
+		space = false
+
+		b.WriteRune(r)
+	}
+
+	name = strings.Trim(b.String(), ". ")
+	if name == "" {
+		name = "untitled"
+	}
+
+	base := name
+	if i := strings.IndexRune(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+
+	if reservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+		name = "_" + name
+	}
+
+	return truncateFilename(name, maxFilenameLen)
+}
+
+// truncateFilename cuts name down to at most max bytes without
+// splitting a multi-byte character. A valid extension is preserved.
+func truncateFilename(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	if !validExt.MatchString(ext) {
+		ext = ""
+	}
+
+	base := name[:len(name)-len(ext)]
+	limit := max - len(ext)
+
+	for limit > 0 && !utf8.RuneStart(base[limit]) {
+		limit--
+	}
+
+	return strings.TrimRight(base[:limit], ". ") + ext
+}
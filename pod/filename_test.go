@@ -0,0 +1,136 @@
+package pod
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := map[string]struct {
+		in       string
+		expected string
+	}{
+		"Plain":                {in: "Episode 1.mp3", expected: "Episode 1.mp3"},
+		"Path separators":      {in: "AC/DC \\ live.mp3", expected: "AC_DC _ live.mp3"},
+		"Reserved characters":  {in: `What? Why: "this" <or> that*|.m4a`, expected: "What_ Why_ _this_ _or_ that__.m4a"},
+		"Control characters":   {in: "Bell\a and\x00 null.mp3", expected: "Bell and null.mp3"},
+		"Collapsed whitespace": {in: "  Lots \n\t of   space  .mp3", expected: "Lots of space .mp3"},
+		"Leading dots":         {in: "...hidden.mp3", expected: "hidden.mp3"},
+		"Trailing dots":        {in: "Ends with dots...", expected: "Ends with dots"},
+		"Parent directory":     {in: "..", expected: "untitled"},
+		"Empty":                {in: "", expected: "untitled"},
+		"Device name":          {in: "con.mp3", expected: "_con.mp3"},
+		"Device name prefix":   {in: "Console.mp3", expected: "Console.mp3"},
+		"Invalid UTF-8":        {in: "bad\xffbyte.mp3", expected: "bad_byte.mp3"},
+	}
+
+	for name, test := range tests {
+		if res := sanitizeFilename(test.in); res != test.expected {
+			t.Errorf("%s: for %q got %q, but expected %q", name, test.in, res, test.expected)
+		}
+	}
+}
+
+func TestSanitizeFilenameLength(t *testing.T) {
+	long := strings.Repeat("ä", 300) + ".mp3"
+
+	res := sanitizeFilename(long)
+	if len(res) > maxFilenameLen {
+		t.Errorf("got name of %d bytes, expected at most %d", len(res), maxFilenameLen)
+	}
+
+	if !strings.HasSuffix(res, ".mp3") {
+		t.Errorf("extension lost while truncating: %q", res)
+	}
+
+	if !strings.HasPrefix(res, "ää") || strings.ContainsRune(res, '�') {
+		t.Errorf("multi-byte character split while truncating: %q", res)
+	}
+}
+
+func TestEpisodeExt(t *testing.T) {
+	tests := map[string]struct {
+		in       *podFile
+		expected string
+	}{
+		"From URL":          {in: &podFile{URL: "https://example.com/ep1.MP3?source=feed", Enc: "audio/mpeg"}, expected: ".mp3"},
+		"From MIME type":    {in: &podFile{URL: "https://example.com/download/42", Enc: "audio/mpeg"}, expected: ".mp3"},
+		"MIME parameters":   {in: &podFile{URL: "https://example.com/42", Enc: "audio/mp4; codecs=mp4a"}, expected: ".m4a"},
+		"Bogus URL ext":     {in: &podFile{URL: "https://example.com/ep.mp3 with spaces", Enc: "audio/ogg"}, expected: ".ogg"},
+		"Nothing to go by":  {in: &podFile{URL: "https://example.com/42"}, expected: ""},
+		"Missing enclosure": {in: nil, expected: ""},
+	}
+
+	for name, test := range tests {
+		if res := episodeExt(test.in); res != test.expected {
+			t.Errorf("%s: got %q, but expected %q", name, res, test.expected)
+		}
+	}
+}
+
+func TestEpisodeFilename(t *testing.T) {
+	date := podTime(time.Date(2020, 12, 24, 18, 0, 0, 0, time.UTC))
+	e := &Episode{
+		Title:   "Merry: Christmas/Holidays?",
+		PubDate: &date,
+		Season:  2,
+		Number:  7,
+		File:    &podFile{URL: "https://example.com/dl?id=7", Enc: "audio/mpeg"},
+	}
+
+	tests := map[string]struct {
+		tmpl     string
+		expected string
+	}{
+		"Default":   {tmpl: "", expected: "Merry_ Christmas_Holidays_.mp3"},
+		"Full":      {tmpl: `{{.PubDate "2006-01-02"}} - {{.Season}}x{{.Number}} {{.Title}}{{.Ext}}`, expected: "2020-12-24 - 2x7 Merry_ Christmas_Holidays_.mp3"},
+		"Padded":    {tmpl: `{{printf "%02d" .Number}}{{.Ext}}`, expected: "07.mp3"},
+		"Podcast":   {tmpl: `{{.Podcast}} {{.Number}}{{.Ext}}`, expected: "foocast 7.mp3"},
+		"Traversal": {tmpl: `../../{{.Title}}`, expected: "_.._Merry_ Christmas_Holidays_"},
+	}
+
+	for name, test := range tests {
+		p := &Podcast{Name: "foocast", Settings: PodSettings{FilenameTemplate: test.tmpl}}
+
+		res, err := p.episodeFilename(e)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		if res != test.expected {
+			t.Errorf("%s: got %q, but expected %q", name, res, test.expected)
+		}
+	}
+}
+
+func TestValidateFilenameTemplate(t *testing.T) {
+	tests := map[string]struct {
+		tmpl  string
+		valid bool
+	}{
+		"Unset":          {tmpl: "", valid: true},
+		"Full":           {tmpl: `{{.PubDate "2006-01-02"}} {{.Season}}x{{.Number}} {{.Title}}{{.Ext}}`, valid: true},
+		"Syntax error":   {tmpl: "{{.Title"},
+		"Unknown field":  {tmpl: "{{.Foo}}{{.Ext}}"},
+		"Wrong argument": {tmpl: "{{.PubDate 1}}{{.Ext}}"},
+		"Missing layout": {tmpl: "{{.PubDate}}{{.Ext}}"},
+		"Empty name":     {tmpl: "{{if false}}{{.Title}}{{end}}"},
+		"Blank name":     {tmpl: "{{if false}}{{.Title}}{{end}} "},
+	}
+
+	for name, test := range tests {
+		err := ValidateFilenameTemplate(test.tmpl)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: invalid template %q accepted", name, test.tmpl)
+		}
+	}
+
+	if err := ValidateFilenameTemplate("{{if false}}x{{end}}"); !errors.Is(err, ErrEmptyFilename) {
+		t.Errorf("expected %v, got %v", ErrEmptyFilename, err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package pod
+
+// isCrossDevice reports false, as failed renames across file systems
+// cannot be told apart from other failures on this platform.
+func isCrossDevice(err error) bool {
+	return false
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package pod
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDevice reports whether err tells that a rename failed as it
+// would cross file systems.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,322 @@
+package pod
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	historyFileName = "feed-history.zip"
+
+	// snapshotLayout is the time layout of snapshot names in the
+	// history archive.
+	snapshotLayout = "20060102T150405Z"
+)
+
+// MaxSnapshots is the number of feed snapshots kept in the history of
+// a podcast. The oldest snapshots are dropped first.
+var MaxSnapshots = 20
+
+// Snapshot is a version of the feed as retrieved at a certain time.
+type Snapshot struct {
+	Time time.Time // Time the feed was retrieved
+
+	data []byte
+}
+
+// FeedDiff lists the differences between the episodes of two feeds.
+type FeedDiff struct {
+	Added    []*Episode    // Episodes only in the newer feed
+	Removed  []*Episode    // Episodes only in the older feed
+	Modified []*ItemChange // Episodes in both feeds that differ
+}
+
+// ItemChange is an episode that differs between two feeds.
+type ItemChange struct {
+	Old     *Episode // The episode in the older feed
+	New     *Episode // The episode in the newer feed
+	Changes []string // Descriptions of the changed fields
+}
+
+// historyFile returns the full file path of the feed history archive.
+func (pod *Podcast) historyFile() string {
+	return filepath.Join(pod.LocalStore, historyFileName)
+}
+
+// Snapshots returns the feed snapshots in the history of the podcast,
+// oldest first.
+func (pod *Podcast) Snapshots() ([]*Snapshot, error) {
+	arc, err := zip.OpenReader(pod.historyFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer arc.Close()
+
+	files := snapshotFiles(&arc.Reader)
+	snaps := make([]*Snapshot, 0, len(files))
+
+	for _, f := range files {
+		data, err := readZipFile(f.file)
+		if err != nil {
+			return nil, err
+		}
+
+		snaps = append(snaps, &Snapshot{Time: f.time, data: data})
+	}
+
+	return snaps, nil
+}
+
+// snapshotFile is a snapshot in the history archive, not read yet.
+type snapshotFile struct {
+	time time.Time
+	file *zip.File
+}
+
+// snapshotFiles returns the snapshots in the history archive arc,
+// oldest first. Other files are left out.
+func snapshotFiles(arc *zip.Reader) []*snapshotFile {
+	files := make([]*snapshotFile, 0, len(arc.File))
+
+	for _, f := range arc.File {
+		t, err := time.Parse(snapshotLayout+".xml", f.Name)
+		if err != nil {
+			continue
+		}
+
+		files = append(files, &snapshotFile{time: t, file: f})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].time.Before(files[j].time)
+	})
+
+	return files
+}
+
+// readZipFile reads the content of the archived file f.
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+// Episodes parses the episodes of the snapshot.
+func (s *Snapshot) Episodes() ([]*Episode, error) {
+	return parseFeed(bytes.NewReader(s.data))
+}
+
+// addSnapshot adds the currently stored feed to the history of the
+// podcast, unless it equals the newest snapshot. Only the newest
+// MaxSnapshots snapshots are kept. The snapshots kept are streamed from
+// the old archive into the new one, only the newest is read to compare
+// it.
+func (pod *Podcast) addSnapshot(now time.Time) error {
+	data, err := pod.readFeedFile()
+	if err != nil {
+		return err
+	}
+
+	var files []*snapshotFile
+
+	arc, err := zip.OpenReader(pod.historyFile())
+	if err == nil {
+		defer func() {
+			if arc != nil {
+				arc.Close()
+			}
+		}()
+
+		files = snapshotFiles(&arc.Reader)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if n := len(files); n > 0 {
+		newest, err := readZipFile(files[n-1].file)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(newest, data) {
+			return nil
+		}
+	}
+
+	snap := &Snapshot{Time: now.UTC().Truncate(time.Second), data: data}
+
+	// Snapshots are named by the second they were taken in.
+	if n := len(files); n > 0 && files[n-1].time.Equal(snap.Time) {
+		files = files[:n-1]
+	}
+
+	if drop := len(files) + 1 - MaxSnapshots; drop > 0 {
+		if drop > len(files) {
+			drop = len(files)
+		}
+
+		files = files[drop:]
+	}
+
+	return writeAtomic(pod.historyFile(), 0644, func(w io.Writer) error {
+		zipper := zip.NewWriter(w)
+
+		for _, f := range files {
+			r, err := f.file.Open()
+			if err != nil {
+				return err
+			}
+
+			err = writeSnapshot(zipper, f.time, r)
+			r.Close()
+
+			if err != nil {
+				return err
+			}
+		}
+
+		// The old archive must be closed before it is replaced.
+		if arc != nil {
+			err := arc.Close()
+			arc = nil
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := writeSnapshot(zipper, snap.Time, bytes.NewReader(snap.data)); err != nil {
+			return err
+		}
+
+		return zipper.Close()
+	})
+}
+
+// writeSnapshot adds the snapshot taken at t with the content of r to
+// the history archive written by zipper.
+func writeSnapshot(zipper *zip.Writer, t time.Time, r io.Reader) error {
+	w, err := zipper.CreateHeader(&zip.FileHeader{
+		Name:     t.Format(snapshotLayout) + ".xml",
+		Method:   zip.Deflate,
+		Modified: t,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+
+	return err
+}
+
+// DiffSnapshots compares the snapshots at positions from and to in the
+// history, counting from 1 for the oldest snapshot.
+func (pod *Podcast) DiffSnapshots(from, to int) (*FeedDiff, error) {
+	snaps, err := pod.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range []int{from, to} {
+		if n < 1 || n > len(snaps) {
+			return nil, fmt.Errorf("%w: %d of %d", ErrNoSnapshot, n, len(snaps))
+		}
+	}
+
+	old, err := snaps[from-1].Episodes()
+	if err != nil {
+		return nil, err
+	}
+
+	cur, err := snaps[to-1].Episodes()
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffFeeds(old, cur), nil
+}
+
+// DiffFeeds compares the episodes of an older and a newer feed.
+// Episodes are matched by their key, see episodeKey.
+func DiffFeeds(old, cur []*Episode) *FeedDiff {
+	diff := new(FeedDiff)
+
+	before := make(map[string]*Episode, len(old))
+	for _, e := range old {
+		before[episodeKey(e)] = e
+	}
+
+	after := make(map[string]bool, len(cur))
+
+	for _, e := range cur {
+		key := episodeKey(e)
+		after[key] = true
+
+		o, ok := before[key]
+		if !ok {
+			diff.Added = append(diff.Added, e)
+			continue
+		}
+
+		if changes := episodeChanges(o, e); len(changes) > 0 {
+			diff.Modified = append(diff.Modified, &ItemChange{Old: o, New: e, Changes: changes})
+		}
+	}
+
+	for _, e := range old {
+		if !after[episodeKey(e)] {
+			diff.Removed = append(diff.Removed, e)
+		}
+	}
+
+	return diff
+}
+
+// episodeChanges describes the differences between two versions of
+// an episode.
+func episodeChanges(old, cur *Episode) []string {
+	var changes []string
+
+	change := func(field string, o, c interface{}) {
+		if o != c {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", field, o, c))
+		}
+	}
+
+	change("title", old.Title, cur.Title)
+	change("published", pubDate(old).Format(time.RFC1123Z), pubDate(cur).Format(time.RFC1123Z))
+	change("season", old.Season, cur.Season)
+	change("episode", old.Number, cur.Number)
+
+	var of, cf podFile
+	if old.File != nil {
+		of = *old.File
+	}
+
+	if cur.File != nil {
+		cf = *cur.File
+	}
+
+	change("enclosure", of.URL, cf.URL)
+	change("length", of.Size, cf.Size)
+	change("type", of.Enc, cf.Enc)
+
+	return changes
+}
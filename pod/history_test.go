@@ -0,0 +1,102 @@
+package pod
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffFeeds(t *testing.T) {
+	old := []*Episode{
+		testEpisode("a", "Kept", 1),
+		testEpisode("b", "Removed", 2),
+		testEpisode("c", "Old title", 3),
+		testEpisode("d", "New file", 4),
+	}
+
+	cur := []*Episode{
+		testEpisode("a", "Kept", 1),
+		testEpisode("c", "New title", 3),
+		testEpisode("d", "New file", 4),
+		testEpisode("e", "Added", 5),
+	}
+	cur[2].File.URL = "https://example.com/d-fixed.mp3"
+	cur[2].File.Size = 4242
+
+	diff := DiffFeeds(old, cur)
+
+	if len(diff.Added) != 1 || diff.Added[0].GUID != "e" {
+		t.Errorf("expected e to be added, got %v", diff.Added)
+	}
+
+	if len(diff.Removed) != 1 || diff.Removed[0].GUID != "b" {
+		t.Errorf("expected b to be removed, got %v", diff.Removed)
+	}
+
+	if len(diff.Modified) != 2 {
+		t.Fatalf("expected 2 modified episodes, got %d", len(diff.Modified))
+	}
+
+	if c := diff.Modified[0]; c.New.GUID != "c" || len(c.Changes) != 1 {
+		t.Errorf("expected the title of c to change, got %v", c.Changes)
+	}
+
+	if c := diff.Modified[1]; c.New.GUID != "d" || len(c.Changes) != 2 {
+		t.Errorf("expected the enclosure of d to change, got %v", c.Changes)
+	}
+}
+
+func TestSnapshotHistory(t *testing.T) {
+	p := fixtureStore(t, "feed.xml", nil)
+
+	defer func(max int) { MaxSnapshots = max }(MaxSnapshots)
+	MaxSnapshots = 2
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// An unchanged feed doesn't make for a new snapshot.
+	for i := 0; i < 2; i++ {
+		if err := p.addSnapshot(start.Add(time.Duration(i) * time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snaps, err := p.Snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snaps) != 1 || !snaps[0].Time.Equal(start) {
+		t.Fatalf("expected a single snapshot from %v, got %d", start, len(snaps))
+	}
+
+	// Changed feeds are added, dropping the oldest beyond MaxSnapshots.
+	for i, feed := range []string{"traversal.xml", "feed.xml"} {
+		q := fixtureStore(t, feed, nil)
+
+		if err := copyFile(q.FeedFile(), p.FeedFile()); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.addSnapshot(start.Add(time.Duration(i+2) * time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snaps, err = p.Snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snaps) != 2 || !snaps[0].Time.Equal(start.Add(2*time.Hour)) {
+		t.Fatalf("expected the two newest snapshots, got %d", len(snaps))
+	}
+
+	diff, err := p.DiffSnapshots(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff.Added) != 3 || len(diff.Removed) != 8 {
+		t.Errorf("expected 3 added and 8 removed episodes, got %d and %d", len(diff.Added), len(diff.Removed))
+	}
+}
@@ -0,0 +1,167 @@
+package pod
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strconv"
+)
+
+const (
+	id3HeaderLen = 10
+	id3Padding   = 1024
+
+	id3EncUTF8       = 3
+	id3PicFrontCover = 3
+)
+
+// id3Replaced lists the frames gopodgrab writes, along with ID3v2.3
+// date frames superseded by TDRC. Frames by these IDs are dropped
+// from existing tags.
+var id3Replaced = map[string]bool{
+	"TIT2": true, "TALB": true, "TPE1": true, "TPE2": true, "TDRC": true,
+	"TRCK": true, "TPOS": true, "TCON": true, "COMM": true, "APIC": true,
+	"TYER": true, "TDAT": true, "TIME": true, "TRDA": true,
+}
+
+// writeID3 writes an ID3v2.4 tag with the tags into the MP3 file at
+// path, replacing an existing ID3v2 tag. Frames of the existing tag
+// not written by gopodgrab are kept if possible.
+func writeID3(path string, t *Tags) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	kept, tagLen, err := readID3Frames(f)
+	f.Close()
+
+	if err != nil {
+		return err
+	}
+
+	var frames bytes.Buffer
+
+	text := func(id, value string) {
+		if value != "" {
+			writeID3Frame(&frames, id, append([]byte{id3EncUTF8}, value...))
+		}
+	}
+
+	text("TIT2", t.Title)
+	text("TALB", t.Podcast)
+	text("TPE1", t.Author)
+	text("TPE2", t.Podcast)
+	text("TCON", "Podcast")
+
+	if !t.Date.IsZero() {
+		text("TDRC", t.Date.UTC().Format("2006-01-02T15:04:05"))
+	}
+
+	if t.Episode > 0 {
+		text("TRCK", strconv.Itoa(t.Episode))
+	}
+
+	if t.Season > 0 {
+		text("TPOS", strconv.Itoa(t.Season))
+	}
+
+	if t.Description != "" {
+		// Encoding, language and an empty short description.
+		comm := append([]byte{id3EncUTF8}, "eng\x00"...)
+		writeID3Frame(&frames, "COMM", append(comm, t.Description...))
+	}
+
+	if len(t.Cover) > 0 {
+		apic := append([]byte{id3EncUTF8}, t.CoverMIME...)
+		apic = append(apic, 0, id3PicFrontCover, 0)
+		writeID3Frame(&frames, "APIC", append(apic, t.Cover...))
+	}
+
+	frames.Write(kept)
+
+	tag := make([]byte, id3HeaderLen, id3HeaderLen+frames.Len()+id3Padding)
+	copy(tag, "ID3\x04\x00\x00")
+	putSyncsafe(tag[6:], frames.Len()+id3Padding)
+	tag = append(tag, frames.Bytes()...)
+	tag = append(tag, make([]byte, id3Padding)...)
+
+	return spliceFile(path, 0, tagLen, tag)
+}
+
+// writeID3Frame appends an ID3v2.4 frame to buf.
+func writeID3Frame(buf *bytes.Buffer, id string, data []byte) {
+	header := make([]byte, 10)
+	copy(header, id)
+	putSyncsafe(header[4:], len(data))
+	buf.Write(header)
+	buf.Write(data)
+}
+
+// readID3Frames reads the ID3v2 tag at the start of r. It returns the
+// raw frames worth keeping, re-encoded for ID3v2.4, and the length of
+// the whole tag. Without a tag both are empty. Frames are only kept
+// from ID3v2.3 and ID3v2.4 tags without unsynchronisation and extended
+// header, and only if they carry no flags.
+func readID3Frames(r io.Reader) ([]byte, int64, error) {
+	header := make([]byte, id3HeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:3]) != "ID3" {
+		return nil, 0, nil
+	}
+
+	version, flags := header[3], header[5]
+	size := syncsafe(header[6:])
+	tagLen := int64(id3HeaderLen + size)
+
+	if version == 4 && flags&0x10 != 0 {
+		tagLen += id3HeaderLen
+	}
+
+	if version < 3 || version > 4 || flags&0xc0 != 0 {
+		return nil, tagLen, nil
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, 0, err
+	}
+
+	var kept bytes.Buffer
+
+	for len(body) >= 10 && body[0] != 0 {
+		id := string(body[:4])
+
+		var n int
+		if version == 4 {
+			n = syncsafe(body[4:8])
+		} else {
+			n = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+
+		if n < 0 || 10+n > len(body) {
+			break
+		}
+
+		if !id3Replaced[id] && body[8] == 0 && body[9] == 0 {
+			writeID3Frame(&kept, id, body[10:10+n])
+		}
+
+		body = body[10+n:]
+	}
+
+	return kept.Bytes(), tagLen, nil
+}
+
+// syncsafe decodes a 28 bit synchsafe integer as used by ID3v2.
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// putSyncsafe encodes n as a 28 bit synchsafe integer into b.
+func putSyncsafe(b []byte, n int) {
+	b[0] = byte(n>>21) & 0x7f
+	b[1] = byte(n>>14) & 0x7f
+	b[2] = byte(n>>7) & 0x7f
+	b[3] = byte(n) & 0x7f
+}
@@ -0,0 +1,270 @@
+package pod
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const indexFileName = "index.json"
+
+// storeIndex records which file in the local store belongs to which
+// episode of the feed. It lives next to the feed in the local store.
+type storeIndex struct {
+	Episodes map[string]*storedEpisode `json:"episodes"`        // Stored episodes by episode key
+	Cover    *storedImage              `json:"cover,omitempty"` // Cover art of the podcast
+}
+
+// storedEpisode is the record of a single episode in the local store.
+type storedEpisode struct {
+	File       string       `json:"file"`                 // Name of the episode file in the local store
+	Title      string       `json:"title"`                // Title of the episode at the time of download
+	URL        string       `json:"url"`                  // Enclosure URL the episode was downloaded from
+	Size       int64        `json:"size"`                 // Enclosure length declared by the feed
+	Published  time.Time    `json:"published,omitempty"`  // Publishing date of the episode
+	Downloaded time.Time    `json:"downloaded,omitempty"` // Time of download, zero for adopted files
+	Pruned     time.Time    `json:"pruned,omitempty"`     // Time the file was removed by retention rules
+	Vanished   time.Time    `json:"vanished,omitempty"`   // Time the episode was noticed missing from the feed
+	Changed    time.Time    `json:"changed,omitempty"`    // Time a changed enclosure was noticed in the feed
+	Image      *storedImage `json:"image,omitempty"`      // Artwork of the episode saved next to the file
+}
+
+// episodeKey identifies an episode across feed refreshes. The GUID is
+// preferred, feeds without GUIDs fall back to the enclosure URL and
+// finally the title.
+func episodeKey(e *Episode) string {
+	if e.GUID != "" {
+		return e.GUID
+	}
+
+	if e.File != nil && e.File.URL != "" {
+		return e.File.URL
+	}
+
+	return e.Title
+}
+
+// indexFile returns the full file path of the store index.
+func (pod *Podcast) indexFile() string {
+	return filepath.Join(pod.LocalStore, indexFileName)
+}
+
+// readIndex reads the store index of the podcast. A missing index
+// results in an empty one.
+func (pod *Podcast) readIndex() (*storeIndex, error) {
+	idx := &storeIndex{Episodes: make(map[string]*storedEpisode)}
+
+	buf, err := ioutil.ReadFile(pod.indexFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(buf, idx); err != nil {
+		return nil, fmt.Errorf("%s: %w", pod.indexFile(), err)
+	}
+
+	if idx.Episodes == nil {
+		idx.Episodes = make(map[string]*storedEpisode)
+	}
+
+	return idx, nil
+}
+
+// writeIndex replaces the store index of the podcast with idx.
+func (pod *Podcast) writeIndex(idx *storeIndex) error {
+	buf, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(pod.indexFile(), buf, 0644)
+}
+
+// record adds episode e stored under file to the index.
+func (idx *storeIndex) record(e *Episode, file string, downloaded time.Time) {
+	se := &storedEpisode{
+		File:       file,
+		Title:      e.Title,
+		Published:  pubDate(e),
+		Downloaded: downloaded,
+	}
+
+	if e.File != nil {
+		se.URL = e.File.URL
+		se.Size = e.File.Size
+	}
+
+	idx.Episodes[episodeKey(e)] = se
+}
+
+// assignFilenames works out the file name of every episode in eps and
+// returns the ones not yet in the local store. Episodes already in the
+// index keep their recorded file name. Files in the store that predate
+// the index are adopted by the episode they were most likely downloaded
+// for. All other episodes get a name unique within the store and batch,
+// disambiguated by publishing date or a suffix derived from the episode
+// key. Reports whether files were adopted into the index.
+func (pod *Podcast) assignFilenames(eps []*Episode, idx *storeIndex, files map[string]string) ([]*Episode, bool, error) {
+	taken := make(map[string]bool, len(idx.Episodes))
+	for _, se := range idx.Episodes {
+		taken[strings.ToLower(se.File)] = true
+	}
+
+	// Candidate names of episodes not in the index, grouped by their
+	// name without extension as legacy files are matched that way.
+	var pending []*Episode
+	claims := make(map[string][]*Episode)
+	seen := make(map[string]bool, len(eps))
+
+	for _, e := range eps {
+		if e.File == nil || seen[episodeKey(e)] {
+			continue
+		}
+		seen[episodeKey(e)] = true
+
+		if se, ok := idx.Episodes[episodeKey(e)]; ok {
+			e.file = se.File
+			continue
+		}
+
+		name, err := pod.episodeFilename(e)
+		if err != nil {
+			return nil, false, err
+		}
+
+		e.file = name
+		pending = append(pending, e)
+
+		base := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+		claims[base] = append(claims[base], e)
+	}
+
+	adopted := false
+
+	for base, claimants := range claims {
+		file, ok := files[base]
+		if !ok || taken[strings.ToLower(file)] {
+			continue
+		}
+
+		e := likeliestOwner(claimants, filepath.Join(pod.LocalStore, file))
+		e.file = file
+		idx.record(e, file, time.Time{})
+		taken[strings.ToLower(file)] = true
+		adopted = true
+	}
+
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[strings.ToLower(file)] = true
+	}
+
+	var newEpis []*Episode
+
+	for _, e := range pending {
+		if _, ok := idx.Episodes[episodeKey(e)]; ok {
+			continue
+		}
+
+		e.file = uniqueFilename(e, e.file, func(name string) bool {
+			name = strings.ToLower(name)
+			return taken[name] || present[name]
+		})
+		taken[strings.ToLower(e.file)] = true
+
+		newEpis = append(newEpis, e)
+	}
+
+	return newEpis, adopted, nil
+}
+
+// likeliestOwner picks the episode a legacy file at path was downloaded
+// for. An episode declaring exactly the size of the file wins, otherwise
+// the earliest published one, which was around for the longest time.
+func likeliestOwner(claimants []*Episode, path string) *Episode {
+	if len(claimants) == 1 {
+		return claimants[0]
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		for _, e := range claimants {
+			if e.File.Size == info.Size() {
+				return e
+			}
+		}
+	}
+
+	sorted := make([]*Episode, len(claimants))
+	copy(sorted, claimants)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return pubDate(sorted[i]).Before(pubDate(sorted[j]))
+	})
+
+	return sorted[0]
+}
+
+// uniqueFilename returns name if it isn't used yet. Otherwise the
+// publishing date of e is added to the name, then a short hash of the
+// episode key, and as a last resort a counter.
+func uniqueFilename(e *Episode, name string, used func(string) bool) string {
+	if !used(name) {
+		return name
+	}
+
+	var suffixes []string
+
+	if t := pubDate(e); !t.IsZero() {
+		suffixes = append(suffixes, t.Format(" (2006-01-02)"))
+	}
+
+	sum := sha1.Sum([]byte(episodeKey(e)))
+	hash := hex.EncodeToString(sum[:4])
+	suffixes = append(suffixes, " ["+hash+"]")
+
+	for _, s := range suffixes {
+		if n := withSuffix(name, s); !used(n) {
+			return n
+		}
+	}
+
+	for i := 2; ; i++ {
+		if n := withSuffix(name, fmt.Sprintf(" [%s-%d]", hash, i)); !used(n) {
+			return n
+		}
+	}
+}
+
+// withSuffix adds suffix to name in front of its extension, shortening
+// the name if needed to stay within maxFilenameLen.
+func withSuffix(name, suffix string) string {
+	ext := filepath.Ext(name)
+	if !validExt.MatchString(ext) {
+		ext = ""
+	}
+
+	base := truncateFilename(strings.TrimSuffix(name, ext), maxFilenameLen-len(suffix)-len(ext))
+
+	return base + suffix + ext
+}
+
+// pubDate returns the publishing date of e, the zero time if unknown.
+func pubDate(e *Episode) time.Time {
+	if e.PubDate == nil {
+		return time.Time{}
+	}
+
+	return time.Time(*e.PubDate)
+}
@@ -0,0 +1,112 @@
+package pod
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testEpisode creates an episode published on the given day of 2021.
+func testEpisode(guid, title string, day int) *Episode {
+	date := podTime(time.Date(2021, 1, day, 8, 0, 0, 0, time.UTC))
+
+	return &Episode{
+		Title:   title,
+		GUID:    guid,
+		PubDate: &date,
+		File:    &podFile{URL: "https://example.com/" + guid + ".mp3", Enc: "audio/mpeg", Size: 1000 + int64(day)},
+	}
+}
+
+func TestDuplicateTitlesInBatch(t *testing.T) {
+	p := &Podcast{Name: "foocast", LocalStore: t.TempDir()}
+	eps := []*Episode{
+		testEpisode("c", "Bonus", 3),
+		testEpisode("b", "Bonus", 2),
+		testEpisode("a", "Bonus", 2),
+	}
+
+	newEpis, _, err := p.assignFilenames(eps, &storeIndex{Episodes: map[string]*storedEpisode{}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Bonus.mp3", "Bonus (2021-01-02).mp3", "Bonus [86f7e437].mp3"}
+
+	if len(newEpis) != len(expected) {
+		t.Fatalf("got %d new episodes, expected %d", len(newEpis), len(expected))
+	}
+
+	for i, e := range newEpis {
+		if e.file != expected[i] {
+			t.Errorf("episode %s: got %q, expected %q", e.GUID, e.file, expected[i])
+		}
+	}
+}
+
+func TestDuplicateTitlesAgainstStore(t *testing.T) {
+	p := &Podcast{Name: "foocast", LocalStore: t.TempDir()}
+
+	// A file downloaded before the store index existed, belonging to
+	// the older of the two episodes as its size shows.
+	if err := ioutil.WriteFile(filepath.Join(p.LocalStore, "Trailer.mp3"), make([]byte, 1001), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	eps := []*Episode{
+		testEpisode("new", "Trailer", 9),
+		testEpisode("old", "Trailer", 1),
+	}
+
+	files, err := p.readStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := p.readIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newEpis, adopted, err := p.assignFilenames(eps, idx, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !adopted {
+		t.Error("expected the existing file to be adopted")
+	}
+
+	if se := idx.Episodes["old"]; se == nil || se.File != "Trailer.mp3" {
+		t.Errorf("expected Trailer.mp3 to be recorded for the old episode, got %+v", se)
+	}
+
+	if len(newEpis) != 1 || newEpis[0].GUID != "new" {
+		t.Fatalf("expected only the new episode to be new, got %v", newEpis)
+	}
+
+	if newEpis[0].file != "Trailer (2021-01-09).mp3" {
+		t.Errorf("got %q for the new episode", newEpis[0].file)
+	}
+
+	// Once downloaded, the name sticks even if the feed changes order
+	// or the template changes.
+	idx.record(newEpis[0], newEpis[0].file, time.Now())
+	p.Settings.FilenameTemplate = "{{.Number}} {{.Title}}{{.Ext}}"
+
+	again := []*Episode{testEpisode("old", "Trailer", 1), testEpisode("new", "Trailer", 9)}
+
+	newEpis, _, err = p.assignFilenames(again, idx, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(newEpis) != 0 {
+		t.Errorf("expected no new episodes, got %d", len(newEpis))
+	}
+
+	if again[0].file != "Trailer.mp3" || again[1].file != "Trailer (2021-01-09).mp3" {
+		t.Errorf("file names changed between runs: %q, %q", again[0].file, again[1].file)
+	}
+}
@@ -0,0 +1,326 @@
+package pod
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// Library is the collection of managed podcasts along with the global
+// settings. It is loaded from its Store once, changes are only stored
+// by Save.
+type Library struct {
+	store    Store
+	settings *Settings
+	pods     map[string]*Podcast
+	added    map[string]bool // Podcasts added, but not saved yet
+}
+
+// NewLibrary loads the library from store.
+func NewLibrary(store Store) (*Library, error) {
+	lib := &Library{store: store}
+	if err := lib.Reload(); err != nil {
+		return nil, err
+	}
+
+	return lib, nil
+}
+
+// OpenLibrary loads the library from the configuration file selected by
+// ConfigFile and Profile.
+func OpenLibrary() (*Library, error) {
+	store, err := DefaultStore()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLibrary(store)
+}
+
+// DefaultLibrary returns the library of the configuration file selected
+// by ConfigFile and Profile without loading it, so a file that fails to
+// load can still be shown or edited. Only the configuration methods,
+// like GetConfig, EditConfig and ConfigInfo, can be used until Reload
+// succeeds.
+func DefaultLibrary() (*Library, error) {
+	store, err := DefaultStore()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Library{store: store}, nil
+}
+
+// Reload loads the library from its store again. Podcasts added, but
+// not saved, are dropped.
+func (lib *Library) Reload() error {
+	conf, err := lib.store.Load()
+	if err != nil {
+		return err
+	}
+
+	lib.settings = conf.Settings
+	lib.pods = conf.Podcasts
+	lib.added = make(map[string]bool)
+
+	for name, pod := range lib.pods {
+		if pod == nil {
+			delete(lib.pods, name)
+			continue
+		}
+
+		pod.lib = lib
+	}
+
+	return nil
+}
+
+// Settings returns the global settings of the library. Changes to them
+// are not saved.
+func (lib *Library) Settings() *Settings {
+	return lib.settings
+}
+
+// Root returns the directory holding the local stores of podcasts added
+// without one, empty if there is none. A relative library_root setting
+// is relative to the directory of the configuration file.
+func (lib *Library) Root() string {
+	root := lib.settings.LibraryRoot
+	if root == "" || filepath.IsAbs(root) {
+		return root
+	}
+
+	if fs, ok := lib.store.(*FileStore); ok {
+		return filepath.Join(filepath.Dir(fs.Path), root)
+	}
+
+	return root
+}
+
+// configFile returns the location of the configuration file of the
+// library. Libraries not stored in a file result in ErrNoConfigFile.
+func (lib *Library) configFile() (string, error) {
+	fs, ok := lib.store.(*FileStore)
+	if !ok {
+		return "", ErrNoConfigFile
+	}
+
+	return fs.Path, nil
+}
+
+// Podcasts returns the podcasts of the library ordered by name.
+func (lib *Library) Podcasts() []*Podcast {
+	pods := make([]*Podcast, 0, len(lib.pods))
+	for _, p := range lib.pods {
+		pods = append(pods, p)
+	}
+
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	return pods
+}
+
+// Get returns the podcast by that name. It results in ErrNoEntry if the
+// library has no such podcast.
+func (lib *Library) Get(name string) (*Podcast, error) {
+	pod, ok := lib.pods[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoEntry, name)
+	}
+
+	return pod, nil
+}
+
+// Has reports whether the library has a podcast by that name.
+func (lib *Library) Has(name string) bool {
+	_, ok := lib.pods[name]
+	return ok
+}
+
+// Add adds the podcast to the library. It is stored by the next Save,
+// unless another podcast by that name was stored meanwhile.
+func (lib *Library) Add(pod *Podcast) error {
+	if pod.Name == ReservedPodName {
+		return ErrReservedName
+	}
+
+	if lib.Has(pod.Name) {
+		return fmt.Errorf("%w: %s", ErrPodExists, pod.Name)
+	}
+
+	if other := lib.storeUser(pod.LocalStore, pod.Name); other != nil {
+		return fmt.Errorf("%w: %s with %s", ErrSharedStore, pod.Name, other.Name)
+	}
+
+	pod.lib = lib
+	lib.pods[pod.Name] = pod
+	lib.added[pod.Name] = true
+
+	return nil
+}
+
+// Save stores the podcasts, replacing the stored podcasts by the same
+// names. Other podcasts in the store are left alone, even if changed
+// by someone else since the library was loaded.
+func (lib *Library) Save(pods ...*Podcast) error {
+	if len(pods) == 0 {
+		return nil
+	}
+
+	err := lib.store.Update(func(conf *Config) error {
+		for _, pod := range pods {
+			if lib.pods[pod.Name] != pod {
+				return fmt.Errorf("%w: %s", ErrNoEntry, pod.Name)
+			}
+
+			if _, ok := conf.Podcasts[pod.Name]; ok && lib.added[pod.Name] {
+				return fmt.Errorf("%w: %s", ErrPodExists, pod.Name)
+			}
+
+			conf.Podcasts[pod.Name] = pod
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range pods {
+		delete(lib.added, pod.Name)
+	}
+
+	return nil
+}
+
+// Remove removes the podcasts by those names from the library and its
+// store. Their local stores are left alone.
+func (lib *Library) Remove(names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	for _, name := range names {
+		if !lib.Has(name) {
+			return fmt.Errorf("%w: %s", ErrNoEntry, name)
+		}
+	}
+
+	err := lib.store.Update(func(conf *Config) error {
+		for _, name := range names {
+			delete(conf.Podcasts, name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		lib.pods[name].lib = nil
+		lib.drop(name)
+	}
+
+	return nil
+}
+
+// New creates a new podcast in the library and intializes the local
+// storage for it. If creation of the local storage fails, or a podcast
+// by that name is already managed by gopodgrab, an error is returned.
+// An empty filenameTmpl selects the global file name template. An empty
+// storageDir selects a directory named after the podcast in the Root.
+func (lib *Library) New(name, feedURL, storageDir, filenameTmpl string) (*Podcast, error) {
+	if name == ReservedPodName {
+		return nil, ErrReservedName
+	}
+
+	storageDir, err := lib.storagePath(name, storageDir, lib.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateFilenameTemplate(filenameTmpl); err != nil {
+		return nil, err
+	}
+
+	if lib.Has(name) {
+		return nil, fmt.Errorf("%w: %s", ErrPodExists, name)
+	}
+
+	pod := &Podcast{
+		Name:       name,
+		FeedURL:    feedURL,
+		LocalStore: storageDir,
+		Settings:   PodSettings{FilenameTemplate: filenameTmpl},
+		lib:        lib,
+	}
+
+	if err := pod.RefreshFeed(); err != nil {
+		return nil, err
+	}
+
+	if err := lib.Add(pod); err != nil {
+		return nil, err
+	}
+
+	if err := lib.Save(pod); err != nil {
+		lib.drop(name)
+		return nil, err
+	}
+
+	return pod, nil
+}
+
+// storagePath returns the absolute path of the local store for the
+// podcast by that name, dir if given and otherwise a directory in root.
+// As different names may sanitize to the same directory, directories
+// in root used by another podcast get a numeric suffix. A dir given
+// that is used by another podcast results in ErrSharedStore.
+func (lib *Library) storagePath(name, dir, root string) (string, error) {
+	if dir != "" {
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return "", err
+		}
+
+		if other := lib.storeUser(dir, name); other != nil {
+			return "", fmt.Errorf("%w: %s with %s", ErrSharedStore, name, other.Name)
+		}
+
+		return dir, nil
+	}
+
+	if root == "" {
+		return "", ErrNoStorage
+	}
+
+	base, err := filepath.Abs(filepath.Join(root, sanitizeFilename(name)))
+	if err != nil {
+		return "", err
+	}
+
+	dir = base
+	for i := 2; lib.storeUser(dir, name) != nil; i++ {
+		dir = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	return dir, nil
+}
+
+// storeUser returns the podcast of the library, other than the one by
+// that name, whose local store is dir. It returns nil if there is none
+// or dir is empty.
+func (lib *Library) storeUser(dir, name string) *Podcast {
+	if dir == "" {
+		return nil
+	}
+
+	for _, p := range lib.pods {
+		if p.Name != name && p.LocalStore != "" && sameDir(p.LocalStore, dir) {
+			return p
+		}
+	}
+
+	return nil
+}
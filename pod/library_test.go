@@ -0,0 +1,120 @@
+package pod
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLibrary(t *testing.T) {
+	store := NewMemoryStore()
+
+	err := store.Update(func(conf *Config) error {
+		conf.Settings.FilenameTemplate = "{{.Title}}{{.Ext}}"
+		conf.Podcasts["foocast"] = &Podcast{Name: "foocast", FeedURL: "http://example.com/foo.xml"}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lib, err := NewLibrary(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foo, err := lib.Get("foocast")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tmpl := foo.Resolved().FilenameTemplate; tmpl != "{{.Title}}{{.Ext}}" {
+		t.Errorf("library settings not applied, got template %q", tmpl)
+	}
+
+	if _, err := lib.Get("barcast"); !errors.Is(err, ErrNoEntry) {
+		t.Errorf("expected ErrNoEntry, got %v", err)
+	}
+
+	if err := lib.Add(&Podcast{Name: "foocast"}); !errors.Is(err, ErrPodExists) {
+		t.Errorf("expected ErrPodExists, got %v", err)
+	}
+
+	if err := lib.Add(&Podcast{Name: ReservedPodName}); !errors.Is(err, ErrReservedName) {
+		t.Errorf("expected ErrReservedName, got %v", err)
+	}
+
+	bar := &Podcast{Name: "barcast"}
+	if err := lib.Add(bar); err != nil {
+		t.Fatal(err)
+	}
+
+	foo.LocalStore = "/tmp/foocast"
+
+	// Nothing is stored before saving.
+	if conf, err := store.Load(); err != nil || len(conf.Podcasts) != 1 || conf.Podcasts["foocast"].LocalStore != "" {
+		t.Fatalf("library changes stored before saving: %v", err)
+	}
+
+	if err := lib.Save(foo, bar); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lib.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	pods := lib.Podcasts()
+	if len(pods) != 2 || pods[0].Name != "barcast" || pods[1].LocalStore != "/tmp/foocast" {
+		t.Errorf("unexpected podcasts after reload: %+v", pods)
+	}
+
+	if err := (&Podcast{Name: "bazcast"}).Save(); !errors.Is(err, ErrNoLibrary) {
+		t.Errorf("expected ErrNoLibrary, got %v", err)
+	}
+}
+
+func TestStoragePath(t *testing.T) {
+	root := t.TempDir()
+
+	lib, err := NewLibrary(NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := lib.storagePath("a:b", "", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dir != filepath.Join(root, "a_b") {
+		t.Errorf("got %s in the library root, expected %s", dir, filepath.Join(root, "a_b"))
+	}
+
+	if err := lib.Add(&Podcast{Name: "a:b", LocalStore: dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Names sanitizing to the same directory get their own.
+	if dir, err = lib.storagePath("a_b", "", root); err != nil || dir != filepath.Join(root, "a_b-2") {
+		t.Errorf("got %s, %v for a colliding name, expected %s", dir, err, filepath.Join(root, "a_b-2"))
+	}
+
+	if _, err := lib.storagePath("a_b", "", ""); !errors.Is(err, ErrNoStorage) {
+		t.Errorf("expected %v without a root, got %v", ErrNoStorage, err)
+	}
+
+	explicit := filepath.Join(root, "elsewhere")
+	if dir, err = lib.storagePath("a_b", explicit, root); err != nil || dir != explicit {
+		t.Errorf("got %s, %v for an explicit directory, expected %s", dir, err, explicit)
+	}
+
+	// The directory of another podcast is not shared.
+	if _, err := lib.storagePath("a_b", filepath.Join(root, "a_b", "."), root); !errors.Is(err, ErrSharedStore) {
+		t.Errorf("expected %v for the directory of another podcast, got %v", ErrSharedStore, err)
+	}
+
+	if err := lib.Add(&Podcast{Name: "a_b", LocalStore: filepath.Join(root, "a_b")}); !errors.Is(err, ErrSharedStore) {
+		t.Errorf("expected %v adding a podcast of the same directory, got %v", ErrSharedStore, err)
+	}
+}
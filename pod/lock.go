@@ -0,0 +1,30 @@
+package pod
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// lockFile takes an exclusive lock on the lock file next to path,
+// blocking until other processes release it. The returned function
+// releases the lock.
+func lockFile(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockExclusive(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		_ = unlock(f)
+		f.Close()
+	}, nil
+}
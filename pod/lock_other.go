@@ -0,0 +1,17 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package pod
+
+import "os"
+
+// lockExclusive does nothing, as file locking is not supported on this
+// platform.
+func lockExclusive(f *os.File) error {
+	return nil
+}
+
+// unlock does nothing.
+func unlock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,341 @@
+package pod
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const moveJournalFileName = "move.json"
+
+// moveJournal records a move of the local store in progress. It is
+// written to both the old and the new store, so an interrupted move
+// can be continued from either side.
+type moveJournal struct {
+	From    string    `json:"from"`    // Directory the local store is moved from
+	To      string    `json:"to"`      // Directory the local store is moved to
+	Started time.Time `json:"started"` // Time the move was started
+}
+
+// Move moves the local store of the podcast to the directory dst and
+// records the new location in the configuration file. The directory
+// is renamed if possible. When crossing file systems, or continuing a
+// move into an existing dst, all files are copied and verified first,
+// then the configuration is updated and only then are the old files
+// removed. Other rename failures are returned. A journal kept in both
+// directories allows calling Move again with the same dst to continue
+// an interrupted move.
+func (pod *Podcast) Move(dst string) error {
+	dst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+
+	src := pod.LocalStore
+
+	// The configuration already points to dst, so only removing the
+	// old store may be left to do.
+	if sameDir(src, dst) {
+		j, err := readMoveJournal(dst)
+		if err != nil || j == nil {
+			return err
+		}
+
+		return pod.finishMove(j)
+	}
+
+	if within(dst, src) {
+		return fmt.Errorf("%w: %s is inside of %s", ErrMoveTarget, dst, src)
+	}
+
+	j, err := readMoveJournal(src)
+	if err != nil {
+		return err
+	}
+
+	if j != nil && !sameDir(j.To, dst) {
+		return fmt.Errorf("%w: to %s", ErrMoveInProgress, j.To)
+	}
+
+	// The directory was renamed, but the configuration not updated.
+	if j == nil && !dirExists(src) {
+		if j, err = readMoveJournal(dst); err != nil {
+			return err
+		}
+
+		if j != nil && sameDir(j.From, src) {
+			return pod.saveMoved(j)
+		}
+	}
+
+	if j == nil {
+		if err := checkMoveTarget(src, dst); err != nil {
+			return err
+		}
+
+		j = &moveJournal{From: src, To: dst, Started: time.Now()}
+		if err := writeMoveJournal(src, j); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		// Only a dst on another file system, or one left by an
+		// interrupted move, is copied to. Other failures are final.
+		if !isCrossDevice(err) && !dirExists(dst) {
+			return err
+		}
+
+		if err := copyStore(j); err != nil {
+			return err
+		}
+	}
+
+	return pod.saveMoved(j)
+}
+
+// saveMoved records the new local store of the move recorded by j in
+// the configuration file and removes the old one.
+func (pod *Podcast) saveMoved(j *moveJournal) error {
+	pod.LocalStore = j.To
+	if err := pod.Save(); err != nil {
+		pod.LocalStore = j.From
+		return err
+	}
+
+	return pod.finishMove(j)
+}
+
+// finishMove removes the old local store of the move recorded by j,
+// once the configuration points to the new one. Only files present in
+// the new store are removed. The journals are removed last.
+func (pod *Podcast) finishMove(j *moveJournal) error {
+	if dirExists(j.From) {
+		files, err := listStore(j.From)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range files {
+			if !sameFile(filepath.Join(j.From, f), filepath.Join(j.To, f)) {
+				return fmt.Errorf("%w: %s differs from the copy in %s", ErrMoveInProgress, f, j.To)
+			}
+
+			if err := os.Remove(filepath.Join(j.From, f)); err != nil {
+				return err
+			}
+		}
+
+		if err := os.Remove(filepath.Join(j.From, moveJournalFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		removeEmptyDirs(j.From)
+	}
+
+	if err := os.Remove(filepath.Join(j.To, moveJournalFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// checkMoveTarget makes sure dst is not in use. It may not exist or be
+// an empty directory.
+func checkMoveTarget(src, dst string) error {
+	if !dirExists(src) {
+		return fmt.Errorf("%w: %s does not exist", ErrMoveTarget, src)
+	}
+
+	entries, err := ioutil.ReadDir(dst)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(entries) > 0 {
+		return fmt.Errorf("%w: %s is not empty", ErrMoveTarget, dst)
+	}
+
+	return nil
+}
+
+// copyStore copies the files of the move recorded by j into the new
+// local store. Files copied and verified by an earlier attempt are
+// skipped.
+func copyStore(j *moveJournal) error {
+	if err := os.MkdirAll(j.To, 0755); err != nil {
+		return err
+	}
+
+	if err := writeMoveJournal(j.To, j); err != nil {
+		return err
+	}
+
+	files, err := listStore(j.From)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		from, to := filepath.Join(j.From, f), filepath.Join(j.To, f)
+
+		if sameFile(from, to) {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return err
+		}
+
+		if err := copyFile(from, to+".part"); err != nil {
+			os.Remove(to + ".part")
+			return err
+		}
+
+		if !sameFile(from, to+".part") {
+			os.Remove(to + ".part")
+			return fmt.Errorf("verifying copy of %s failed", from)
+		}
+
+		if err := os.Rename(to+".part", to); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listStore lists the files in the local store at dir, relative to
+// it. The move journal is left out.
+func listStore(dir string) ([]string, error) {
+	var files []string
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if rel != moveJournalFileName {
+			files = append(files, rel)
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+// sameFile reports whether the files at a and b both exist and have
+// the same content.
+func sameFile(a, b string) bool {
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+
+	ib, err := os.Stat(b)
+	if err != nil || ia.Size() != ib.Size() {
+		return false
+	}
+
+	ha, err := fileHash(a)
+	if err != nil {
+		return false
+	}
+
+	hb, err := fileHash(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ha, hb)
+}
+
+// fileHash returns the SHA-256 hash of the file at path.
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// removeEmptyDirs removes dir and the directories below it if they
+// are empty. Failures are ignored.
+func removeEmptyDirs(dir string) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			removeEmptyDirs(filepath.Join(dir, e.Name()))
+		}
+	}
+
+	_ = os.Remove(dir)
+}
+
+// sameDir reports whether a and b name the same directory path.
+func sameDir(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// readMoveJournal reads the move journal in dir, nil if there is none.
+func readMoveJournal(dir string) (*moveJournal, error) {
+	path := filepath.Join(dir, moveJournalFileName)
+
+	buf, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	j := new(moveJournal)
+	if err := json.Unmarshal(buf, j); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return j, nil
+}
+
+// writeMoveJournal writes the move journal j into dir.
+func writeMoveJournal(dir string, j *moveJournal) error {
+	buf, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(dir, moveJournalFileName), buf, 0644)
+}
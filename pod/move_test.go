@@ -0,0 +1,252 @@
+package pod
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var moveFiles = map[string]string{
+	"episode.mp3":   "episode",
+	"art/cover.jpg": "cover",
+}
+
+// writeStoreFiles writes files, by their path relative to dir, into dir.
+func writeStoreFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// moveFixture returns a podcast saved in a library, with moveFiles in
+// its local store, and the directory to move it to.
+func moveFixture(t *testing.T) (*Library, *Podcast, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	lib, err := NewLibrary(NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Podcast{Name: "foo", LocalStore: filepath.Join(dir, "src")}
+	if err := lib.Add(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lib.Save(p); err != nil {
+		t.Fatal(err)
+	}
+
+	writeStoreFiles(t, p.LocalStore, moveFiles)
+
+	return lib, p, filepath.Join(dir, "dst")
+}
+
+// checkMoved makes sure the podcast was moved from src to dst
+// completely and the configuration points to dst.
+func checkMoved(t *testing.T, lib *Library, p *Podcast, src, dst string) {
+	t.Helper()
+
+	conf, err := lib.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.LocalStore != dst || conf.Podcasts[p.Name].LocalStore != dst {
+		t.Errorf("local store is %s, %s in the configuration, expected %s",
+			p.LocalStore, conf.Podcasts[p.Name].LocalStore, dst)
+	}
+
+	for name, content := range moveFiles {
+		buf, err := ioutil.ReadFile(filepath.Join(dst, name))
+		if err != nil || string(buf) != content {
+			t.Errorf("%s not moved: %q, %v", name, buf, err)
+		}
+	}
+
+	if dirExists(src) {
+		t.Errorf("old store %s not removed", src)
+	}
+
+	if fileExists(filepath.Join(dst, moveJournalFileName)) {
+		t.Error("move journal left behind")
+	}
+}
+
+func TestMove(t *testing.T) {
+	lib, p, dst := moveFixture(t)
+	src := p.LocalStore
+
+	if err := p.Move(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	checkMoved(t, lib, p, src, dst)
+
+	for _, inner := range []string{"inner", "..inner"} {
+		if err := p.Move(filepath.Join(dst, inner)); !errors.Is(err, ErrMoveTarget) {
+			t.Errorf("expected %v moving into the store as %s, got %v", ErrMoveTarget, inner, err)
+		}
+	}
+}
+
+func TestMoveResume(t *testing.T) {
+	// Each phase sets up the state a move interrupted in it leaves.
+	tests := map[string]func(t *testing.T, p *Podcast, j *moveJournal){
+		"Journal written": func(t *testing.T, p *Podcast, j *moveJournal) {
+			if err := writeMoveJournal(j.From, j); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"Copying": func(t *testing.T, p *Podcast, j *moveJournal) {
+			if err := writeMoveJournal(j.From, j); err != nil {
+				t.Fatal(err)
+			}
+
+			// The destination is not empty, so it cannot be renamed
+			// onto and the copy continues. A truncated copy and an
+			// unfinished one are copied again.
+			writeStoreFiles(t, j.To, map[string]string{"episode.mp3": "epi", "art/cover.jpg.part": "co"})
+
+			if err := writeMoveJournal(j.To, j); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"Renamed": func(t *testing.T, p *Podcast, j *moveJournal) {
+			if err := writeMoveJournal(j.From, j); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.Rename(j.From, j.To); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"Saved": func(t *testing.T, p *Podcast, j *moveJournal) {
+			if err := writeMoveJournal(j.From, j); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := copyStore(j); err != nil {
+				t.Fatal(err)
+			}
+
+			p.LocalStore = j.To
+			if err := p.Save(); err != nil {
+				t.Fatal(err)
+			}
+		},
+	}
+
+	for name, setup := range tests {
+		t.Run(name, func(t *testing.T) {
+			lib, p, dst := moveFixture(t)
+			src := p.LocalStore
+
+			setup(t, p, &moveJournal{From: src, To: dst, Started: time.Now()})
+
+			if err := p.Move(dst); err != nil {
+				t.Fatal(err)
+			}
+
+			checkMoved(t, lib, p, src, dst)
+
+			if fileExists(filepath.Join(dst, "art", "cover.jpg.part")) {
+				t.Error("unfinished copy left behind")
+			}
+		})
+	}
+}
+
+func TestMoveInProgress(t *testing.T) {
+	_, p, dst := moveFixture(t)
+
+	j := &moveJournal{From: p.LocalStore, To: dst, Started: time.Now()}
+	if err := writeMoveJournal(p.LocalStore, j); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Move(dst + "-other"); !errors.Is(err, ErrMoveInProgress) {
+		t.Errorf("expected %v moving elsewhere, got %v", ErrMoveInProgress, err)
+	}
+}
+
+func TestMoveKeepsDifferingSource(t *testing.T) {
+	_, p, dst := moveFixture(t)
+	src := p.LocalStore
+
+	j := &moveJournal{From: src, To: dst, Started: time.Now()}
+	if err := writeMoveJournal(src, j); err != nil {
+		t.Fatal(err)
+	}
+
+	// A copy differing from the source is copied again.
+	writeStoreFiles(t, dst, map[string]string{"episode.mp3": "episodf"})
+
+	if err := copyStore(j); err != nil {
+		t.Fatal(err)
+	}
+
+	if !sameFile(filepath.Join(src, "episode.mp3"), filepath.Join(dst, "episode.mp3")) {
+		t.Fatal("differing copy not replaced")
+	}
+
+	p.LocalStore = dst
+	if err := p.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The copy got corrupted after the configuration was updated.
+	writeStoreFiles(t, dst, map[string]string{"episode.mp3": "episodf"})
+
+	if err := p.Move(dst); !errors.Is(err, ErrMoveInProgress) {
+		t.Fatalf("expected %v, got %v", ErrMoveInProgress, err)
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(src, "episode.mp3"))
+	if err != nil || string(buf) != "episode" {
+		t.Errorf("differing source file removed: %q, %v", buf, err)
+	}
+
+	if !fileExists(filepath.Join(src, moveJournalFileName)) {
+		t.Error("move journal removed before the move finished")
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	writeStoreFiles(t, dir, map[string]string{"a.mp3": "a", "full/b.mp3": "b"})
+
+	if err := moveFile(filepath.Join(dir, "a.mp3"), filepath.Join(dir, "moved.mp3")); err != nil {
+		t.Fatal(err)
+	}
+
+	if fileExists(filepath.Join(dir, "a.mp3")) || !fileExists(filepath.Join(dir, "moved.mp3")) {
+		t.Error("file not moved")
+	}
+
+	// Renaming onto a non-empty directory fails, the file must not be
+	// copied and removed.
+	err := moveFile(filepath.Join(dir, "moved.mp3"), filepath.Join(dir, "full"))
+	if err == nil {
+		t.Fatal("moved a file onto a directory")
+	}
+
+	if !fileExists(filepath.Join(dir, "moved.mp3")) {
+		t.Error("file removed after failed rename")
+	}
+}
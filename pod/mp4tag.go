@@ -0,0 +1,352 @@
+package pod
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Data types of iTunes metadata items.
+const (
+	mp4TypeBinary = 0
+	mp4TypeUTF8   = 1
+	mp4TypeJPEG   = 13
+	mp4TypePNG    = 14
+)
+
+// mp4Containers are the boxes gopodgrab descends into. All other boxes
+// are kept as they are.
+var mp4Containers = map[string]bool{
+	"moov": true, "trak": true, "mdia": true, "minf": true, "stbl": true,
+	"udta": true, "meta": true, "ilst": true,
+}
+
+// mp4Box is a box of an MP4 file. Containers have children, all other
+// boxes raw data.
+type mp4Box struct {
+	typ      string
+	data     []byte    // Payload of boxes other than containers
+	prefix   []byte    // Version and flags of full box containers (meta)
+	children []*mp4Box // Children of containers
+}
+
+// writeMP4Tags writes the tags as iTunes style metadata into the MP4
+// file at path. The moov box is rewritten, chunk offsets are adjusted
+// if it precedes the media data.
+func writeMP4Tags(path string, t *Tags) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	var moov *mp4Box
+	var moovStart, moovEnd int64
+	var mediaAfter, fragmented bool
+
+	for off := int64(0); off < info.Size(); {
+		typ, hdr, size, err := readMP4Header(f, off, info.Size())
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		switch typ {
+		case "moov":
+			buf := make([]byte, size-hdr)
+			if _, err := f.ReadAt(buf, off+hdr); err != nil {
+				f.Close()
+				return err
+			}
+
+			if moov, err = parseMP4Box("moov", buf); err != nil {
+				f.Close()
+				return err
+			}
+
+			moovStart, moovEnd = off, off+size
+		case "mdat":
+			mediaAfter = mediaAfter || moov != nil
+		case "moof":
+			fragmented = true
+		}
+
+		off += size
+	}
+	f.Close()
+
+	if moov == nil {
+		return fmt.Errorf("%w: %s has no moov box", ErrUnsupportedFormat, path)
+	}
+
+	setMP4Tags(moov, t)
+
+	data := moov.bytes()
+	delta := int64(len(data)) - (moovEnd - moovStart)
+
+	if delta != 0 && mediaAfter {
+		if fragmented {
+			return fmt.Errorf("%w: fragmented MP4", ErrUnsupportedFormat)
+		}
+
+		if err := shiftChunkOffsets(moov, delta); err != nil {
+			return err
+		}
+
+		data = moov.bytes()
+	}
+
+	return spliceFile(path, moovStart, moovEnd, data)
+}
+
+// readMP4Header reads the header of the box at offset off. It returns
+// the box type, the header length and the size of the whole box.
+func readMP4Header(r io.ReaderAt, off, fileSize int64) (string, int64, int64, error) {
+	buf := make([]byte, 16)
+	if _, err := r.ReadAt(buf[:8], off); err != nil {
+		return "", 0, 0, err
+	}
+
+	typ := string(buf[4:8])
+	size := int64(binary.BigEndian.Uint32(buf))
+	hdr := int64(8)
+
+	switch size {
+	case 0:
+		size = fileSize - off
+	case 1:
+		if _, err := r.ReadAt(buf[8:], off+8); err != nil {
+			return "", 0, 0, err
+		}
+
+		size = int64(binary.BigEndian.Uint64(buf[8:]))
+		hdr = 16
+	}
+
+	if size < hdr || off+size > fileSize {
+		return "", 0, 0, fmt.Errorf("%w: corrupt %q box", ErrUnsupportedFormat, typ)
+	}
+
+	return typ, hdr, size, nil
+}
+
+// parseMP4Box parses the payload of a box of type typ.
+func parseMP4Box(typ string, payload []byte) (*mp4Box, error) {
+	box := &mp4Box{typ: typ}
+
+	if !mp4Containers[typ] {
+		box.data = payload
+		return box, nil
+	}
+
+	// A meta box is a full box in MP4 files, but not in QuickTime
+	// files, where its payload directly starts with a child box.
+	if typ == "meta" && len(payload) >= 8 && string(payload[4:8]) != "hdlr" {
+		box.prefix, payload = payload[:4], payload[4:]
+	}
+
+	for len(payload) > 0 {
+		if len(payload) < 8 {
+			return nil, fmt.Errorf("%w: truncated %q box", ErrUnsupportedFormat, typ)
+		}
+
+		size := uint64(binary.BigEndian.Uint32(payload))
+		childTyp := string(payload[4:8])
+		hdr := uint64(8)
+
+		switch size {
+		case 0:
+			size = uint64(len(payload))
+		case 1:
+			if len(payload) < 16 {
+				return nil, fmt.Errorf("%w: truncated %q box", ErrUnsupportedFormat, childTyp)
+			}
+
+			size = binary.BigEndian.Uint64(payload[8:])
+			hdr = 16
+		}
+
+		if size < hdr || size > uint64(len(payload)) {
+			return nil, fmt.Errorf("%w: corrupt %q box", ErrUnsupportedFormat, childTyp)
+		}
+
+		var child *mp4Box
+		var err error
+
+		// Items of the metadata list are kept as raw data.
+		if typ == "ilst" {
+			child = &mp4Box{typ: childTyp, data: payload[hdr:size]}
+		} else if child, err = parseMP4Box(childTyp, payload[hdr:size]); err != nil {
+			return nil, err
+		}
+
+		box.children = append(box.children, child)
+		payload = payload[size:]
+	}
+
+	return box, nil
+}
+
+// bytes encodes the box including its header.
+func (b *mp4Box) bytes() []byte {
+	payload := append([]byte{}, b.prefix...)
+
+	if b.children == nil {
+		payload = append(payload, b.data...)
+	}
+
+	for _, c := range b.children {
+		payload = append(payload, c.bytes()...)
+	}
+
+	return mp4Encode(b.typ, payload)
+}
+
+// mp4Encode prefixes payload with the header of a box of type typ.
+func mp4Encode(typ string, payload []byte) []byte {
+	buf := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(8+len(payload)))
+	copy(buf[4:], typ)
+
+	return append(buf, payload...)
+}
+
+// child returns the first child of type typ, creating it if missing.
+func (b *mp4Box) child(typ string) *mp4Box {
+	for _, c := range b.children {
+		if c.typ == typ {
+			return c
+		}
+	}
+
+	c := &mp4Box{typ: typ, children: []*mp4Box{}}
+	b.children = append(b.children, c)
+
+	return c
+}
+
+// setMP4Tags sets the tags in the metadata item list of moov, which is
+// created if missing. Other items are kept.
+func setMP4Tags(moov *mp4Box, t *Tags) {
+	meta := moov.child("udta").child("meta")
+
+	if meta.prefix == nil && len(meta.children) == 0 {
+		meta.prefix = make([]byte, 4)
+
+		// Handler reference: pre-defined, handler type "mdir",
+		// reserved "appl" and zeros, empty name.
+		hdlr := make([]byte, 25)
+		copy(hdlr[8:], "mdirappl")
+		meta.children = append(meta.children, &mp4Box{typ: "hdlr", data: hdlr})
+	}
+
+	ilst := meta.child("ilst")
+
+	set := func(typ string, dataType uint32, value []byte) {
+		data := make([]byte, 8, 8+len(value))
+		binary.BigEndian.PutUint32(data, dataType)
+		item := &mp4Box{typ: typ, data: mp4Encode("data", append(data, value...))}
+
+		for i, c := range ilst.children {
+			if c.typ == typ {
+				ilst.children[i] = item
+				return
+			}
+		}
+
+		ilst.children = append(ilst.children, item)
+	}
+
+	text := func(typ, value string) {
+		if value != "" {
+			set(typ, mp4TypeUTF8, []byte(value))
+		}
+	}
+
+	text("\xa9nam", t.Title)
+	text("\xa9alb", t.Podcast)
+	text("\xa9ART", t.Author)
+	text("aART", t.Podcast)
+	text("\xa9gen", "Podcast")
+	text("desc", t.Description)
+
+	if !t.Date.IsZero() {
+		text("\xa9day", t.Date.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+
+	if t.Episode > 0 {
+		trkn := make([]byte, 8)
+		binary.BigEndian.PutUint16(trkn[2:], uint16(t.Episode))
+		set("trkn", mp4TypeBinary, trkn)
+	}
+
+	if t.Season > 0 {
+		disk := make([]byte, 6)
+		binary.BigEndian.PutUint16(disk[2:], uint16(t.Season))
+		set("disk", mp4TypeBinary, disk)
+	}
+
+	if len(t.Cover) > 0 {
+		typ := uint32(mp4TypeJPEG)
+		if t.CoverMIME == "image/png" {
+			typ = mp4TypePNG
+		}
+
+		set("covr", typ, t.Cover)
+	}
+}
+
+// shiftChunkOffsets adds delta to all chunk offsets in the sample
+// tables of moov.
+func shiftChunkOffsets(box *mp4Box, delta int64) error {
+	for _, c := range box.children {
+		if err := shiftChunkOffsets(c, delta); err != nil {
+			return err
+		}
+	}
+
+	if box.typ != "stco" && box.typ != "co64" {
+		return nil
+	}
+
+	if len(box.data) < 8 {
+		return errors.New("corrupt chunk offset box")
+	}
+
+	n := int(binary.BigEndian.Uint32(box.data[4:]))
+	entries := box.data[8:]
+
+	width := 4
+	if box.typ == "co64" {
+		width = 8
+	}
+
+	if len(entries) < n*width {
+		return errors.New("corrupt chunk offset box")
+	}
+
+	for i := 0; i < n; i++ {
+		e := entries[i*width:]
+
+		if width == 8 {
+			binary.BigEndian.PutUint64(e, uint64(int64(binary.BigEndian.Uint64(e))+delta))
+			continue
+		}
+
+		off := int64(binary.BigEndian.Uint32(e)) + delta
+		if off < 0 || off > 1<<32-1 {
+			return errors.New("chunk offset out of range")
+		}
+
+		binary.BigEndian.PutUint32(e, uint32(off))
+	}
+
+	return nil
+}
@@ -0,0 +1,354 @@
+package pod
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// opmlDoc is an OPML 1.0 or 2.0 document.
+type opmlDoc struct {
+	XMLName     xml.Name       `xml:"opml"`
+	Version     string         `xml:"version,attr"`
+	Title       string         `xml:"head>title"`
+	DateCreated string         `xml:"head>dateCreated,omitempty"`
+	Body        []*opmlOutline `xml:"body>outline"`
+}
+
+// opmlOutline is an outline of an OPML document. Outlines with a feed
+// URL are subscriptions, others group the outlines below them.
+type opmlOutline struct {
+	Text     string         `xml:"text,attr"`
+	Title    string         `xml:"title,attr,omitempty"`
+	Type     string         `xml:"type,attr,omitempty"`
+	XMLURL   string         `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string         `xml:"htmlUrl,attr,omitempty"`
+	Outlines []*opmlOutline `xml:"outline"`
+}
+
+// Subscription is a podcast listed in an OPML document.
+type Subscription struct {
+	Title    string // Title of the podcast
+	FeedURL  string // URL of the podcast feed
+	Category string // Path of the outlines grouping it, like "News/Tech"
+}
+
+// ParseOPML reads the subscriptions from the OPML document r, looking
+// into nested category outlines. A feed listed more than once is
+// returned once.
+func ParseOPML(r io.Reader) ([]*Subscription, error) {
+	doc := new(opmlDoc)
+
+	dec := xml.NewTokenDecoder(&depthLimiter{dec: xml.NewDecoder(io.LimitReader(r, MaxFeedSize)), max: maxXMLDepth})
+	if err := dec.Decode(doc); err != nil {
+		return nil, fmt.Errorf("reading OPML: %w", err)
+	}
+
+	var subs []*Subscription
+	seen := make(map[string]bool)
+
+	var walk func(outlines []*opmlOutline, category []string)
+	walk = func(outlines []*opmlOutline, category []string) {
+		for _, o := range outlines {
+			title := strings.TrimSpace(o.Title)
+			if title == "" {
+				title = strings.TrimSpace(o.Text)
+			}
+
+			feed := strings.TrimSpace(o.XMLURL)
+			if feed != "" && !seen[feed] {
+				seen[feed] = true
+				subs = append(subs, &Subscription{
+					Title:    title,
+					FeedURL:  feed,
+					Category: strings.Join(category, "/"),
+				})
+			}
+
+			if feed == "" && title != "" {
+				walk(o.Outlines, append(category[:len(category):len(category)], title))
+			} else {
+				walk(o.Outlines, category)
+			}
+		}
+	}
+
+	walk(doc.Body, nil)
+
+	return subs, nil
+}
+
+// WriteOPML writes the podcasts of the library to w as an OPML 2.0
+// document. Podcasts are listed with the channel title of their stored
+// feed, or their name if there is none, and grouped in outlines by
+// their category unless flat.
+func (lib *Library) WriteOPML(w io.Writer, flat bool) error {
+	doc := &opmlDoc{
+		Version:     "2.0",
+		Title:       "gopodgrab subscriptions",
+		DateCreated: time.Now().Format(time.RFC1123Z),
+	}
+
+	groups := make(map[string]*opmlOutline)
+
+	// outlines returns the outlines of the category, creating the
+	// outlines of the category and its parents as needed.
+	var outlines func(category string) *[]*opmlOutline
+	outlines = func(category string) *[]*opmlOutline {
+		if flat || category == "" {
+			return &doc.Body
+		}
+
+		if g, ok := groups[category]; ok {
+			return &g.Outlines
+		}
+
+		parent, text := "", category
+		if i := strings.LastIndex(category, "/"); i >= 0 {
+			parent, text = category[:i], category[i+1:]
+		}
+
+		g := &opmlOutline{Text: text}
+		groups[category] = g
+
+		siblings := outlines(parent)
+		*siblings = append(*siblings, g)
+
+		return &g.Outlines
+	}
+
+	for _, pod := range lib.Podcasts() {
+		title := pod.Name
+		if ch, _, err := pod.readFeedChannel(); err == nil && strings.TrimSpace(ch.Title) != "" {
+			title = strings.TrimSpace(ch.Title)
+		}
+
+		category := strings.Trim(pod.Category, "/")
+		list := outlines(category)
+		*list = append(*list, &opmlOutline{Text: title, Title: title, Type: "rss", XMLURL: pod.FeedURL})
+	}
+
+	sortOutlines(doc.Body)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// sortOutlines orders the outlines by their text, feeds before groups.
+func sortOutlines(outlines []*opmlOutline) {
+	sort.SliceStable(outlines, func(i, j int) bool {
+		a, b := outlines[i], outlines[j]
+		if (a.XMLURL == "") != (b.XMLURL == "") {
+			return a.XMLURL != ""
+		}
+
+		return strings.ToLower(a.Text) < strings.ToLower(b.Text)
+	})
+
+	for _, o := range outlines {
+		sortOutlines(o.Outlines)
+	}
+}
+
+// ImportResult is the outcome of importing a subscription.
+type ImportResult struct {
+	Sub      *Subscription
+	Podcast  *Podcast // Podcast added, nil if skipped or failed
+	Existing *Podcast // Managed podcast of the same feed, if skipped
+	Err      error    // Reason the import failed
+}
+
+// Import adds the subscriptions as new podcasts to the library. Names
+// are derived from the titles and made unique, the local stores are
+// directories named after them in storageRoot, or the library Root if
+// empty. Subscriptions of feeds managed already are skipped. With jobs
+// above zero the feeds are fetched, that many at a time, and podcasts
+// whose feed fails are not added. Otherwise the feeds are fetched by
+// NewEpisodes on the next update.
+func (lib *Library) Import(subs []*Subscription, storageRoot string, jobs int) []*ImportResult {
+	if storageRoot == "" {
+		storageRoot = lib.Root()
+	}
+
+	results := make([]*ImportResult, len(subs))
+
+	for i, sub := range subs {
+		res := &ImportResult{Sub: sub}
+		results[i] = res
+
+		if res.Existing = lib.byFeed(sub.FeedURL); res.Existing != nil {
+			continue
+		}
+
+		if err := checkURL(sub.FeedURL); err != nil {
+			res.Err = err
+			continue
+		}
+
+		name := lib.uniqueName(podName(sub))
+
+		dir, err := lib.storagePath(name, "", storageRoot)
+		if err != nil {
+			res.Err = err
+			continue
+		}
+
+		pod := &Podcast{Name: name, FeedURL: sub.FeedURL, LocalStore: dir, Category: sub.Category}
+		if res.Err = lib.Add(pod); res.Err == nil {
+			res.Podcast = pod
+		}
+	}
+
+	if jobs > 0 {
+		lib.fetchImported(results, jobs)
+	}
+
+	var added []*Podcast
+	for _, res := range results {
+		if res.Podcast != nil {
+			added = append(added, res.Podcast)
+		}
+	}
+
+	if err := lib.Save(added...); err != nil {
+		for _, res := range results {
+			if res.Podcast != nil {
+				lib.drop(res.Podcast.Name)
+				res.Podcast, res.Err = nil, err
+			}
+		}
+	}
+
+	return results
+}
+
+// fetchImported fetches the feeds of the podcasts added by results,
+// jobs at a time. Podcasts whose feed fails are dropped again.
+func (lib *Library) fetchImported(results []*ImportResult, jobs int) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, jobs)
+
+	for _, res := range results {
+		if res.Podcast == nil {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(res *ImportResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res.Err = res.Podcast.RefreshFeed()
+		}(res)
+	}
+
+	wg.Wait()
+
+	for _, res := range results {
+		if res.Podcast != nil && res.Err != nil {
+			lib.drop(res.Podcast.Name)
+			res.Podcast = nil
+		}
+	}
+}
+
+// byFeed returns the podcast of the library with the feed at feedURL,
+// nil if there is none.
+func (lib *Library) byFeed(feedURL string) *Podcast {
+	for _, p := range lib.pods {
+		if sameFeed(p.FeedURL, feedURL) {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// sameFeed reports whether the feed URLs a and b are the same, ignoring
+// case of the scheme and host as well as a trailing slash.
+func sameFeed(a, b string) bool {
+	norm := func(raw string) string {
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			return raw
+		}
+
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		u.Path = strings.TrimSuffix(u.Path, "/")
+
+		return u.String()
+	}
+
+	return norm(a) == norm(b)
+}
+
+// uniqueName returns name, or name with a number appended if the
+// library has a podcast by that name.
+func (lib *Library) uniqueName(name string) string {
+	unique := name
+	for i := 2; lib.Has(unique) || unique == ReservedPodName; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+
+	return unique
+}
+
+// podName derives a podcast name from the subscription, the title in
+// lower case with words joined by dashes. Subscriptions without a
+// usable title are named after the host of the feed.
+func podName(sub *Subscription) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(sub.Title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+
+		dash = true
+	}
+
+	if b.Len() > 0 {
+		return b.String()
+	}
+
+	if u, err := url.Parse(sub.FeedURL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+
+	return "podcast"
+}
+
+// drop removes the podcast by that name from the library, without
+// touching the store.
+func (lib *Library) drop(name string) {
+	delete(lib.pods, name)
+	delete(lib.added, name)
+}
@@ -0,0 +1,193 @@
+package pod
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testOPML = `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+<head><title>Subscriptions</title></head>
+<body>
+  <outline text="Foo Cast!" type="rss" xmlUrl="%[1]s/foo.xml"/>
+  <outline text="News">
+    <outline text="Tech">
+      <outline title="Bar &amp; Baz" text="ignored" xmlUrl="%[1]s/bar.xml"/>
+      <outline text="Foo Cast" xmlUrl="%[1]s/foo2.xml"/>
+    </outline>
+    <outline text="Broken" xmlUrl="%[1]s/missing.xml"/>
+  </outline>
+  <outline text="Again" xmlUrl="%[1]s/foo.xml"/>
+  <outline text="Managed" xmlUrl="%[1]s/managed.xml/"/>
+</body>
+</opml>`
+
+func TestImport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.xml" {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write([]byte(`<rss><channel><title>Feed</title></channel></rss>`))
+	}))
+	defer srv.Close()
+
+	subs, err := ParseOPML(strings.NewReader(strings.ReplaceAll(testOPML, "%[1]s", srv.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Subscription{
+		{Title: "Foo Cast!", FeedURL: srv.URL + "/foo.xml"},
+		{Title: "Bar & Baz", FeedURL: srv.URL + "/bar.xml", Category: "News/Tech"},
+		{Title: "Foo Cast", FeedURL: srv.URL + "/foo2.xml", Category: "News/Tech"},
+		{Title: "Broken", FeedURL: srv.URL + "/missing.xml", Category: "News"},
+		{Title: "Managed", FeedURL: srv.URL + "/managed.xml/"},
+	}
+
+	if !reflect.DeepEqual(subs, want) {
+		for _, s := range subs {
+			t.Logf("%+v", s)
+		}
+		t.Fatal("unexpected subscriptions")
+	}
+
+	lib, err := NewLibrary(NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	managed := &Podcast{Name: "managed", FeedURL: srv.URL + "/managed.xml"}
+	if err := lib.Add(managed); err != nil {
+		t.Fatal(err)
+	}
+
+	results := lib.Import(subs, t.TempDir(), 2)
+
+	names := make([]string, len(results))
+	for i, res := range results {
+		switch {
+		case res.Existing != nil:
+			names[i] = "=" + res.Existing.Name
+		case res.Err != nil:
+			names[i] = "!"
+		default:
+			names[i] = res.Podcast.Name
+		}
+	}
+
+	if want := []string{"foo-cast", "bar-baz", "foo-cast-2", "!", "=managed"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got results %v, expected %v", names, want)
+	}
+
+	if lib.Has("broken") {
+		t.Error("podcast with a broken feed added")
+	}
+
+	bar, err := lib.Get("bar-baz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bar.Category != "News/Tech" || !fileExists(bar.FeedFile()) {
+		t.Errorf("unexpected podcast %+v", bar)
+	}
+
+	conf, err := lib.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conf.Podcasts) != 3 {
+		t.Errorf("got %d podcasts stored, expected 3", len(conf.Podcasts))
+	}
+}
+
+func TestWriteOPML(t *testing.T) {
+	lib, err := NewLibrary(NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []*Podcast{
+		{Name: "zed", FeedURL: "http://example.com/zed.xml", Category: "News/Tech"},
+		{Name: "foo", FeedURL: "http://example.com/foo.xml"},
+		{Name: "bar", FeedURL: "http://example.com/bar.xml?a=1&b=2", Category: "News"},
+		{Name: "baz", FeedURL: "http://example.com/baz.xml", Category: "News/Tech"},
+	} {
+		if err := lib.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf strings.Builder
+	if err := lib.WriteOPML(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `<opml version="2.0">`) {
+		t.Errorf("no OPML 2.0 document:\n%s", buf.String())
+	}
+
+	subs, err := ParseOPML(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Subscription{
+		{Title: "foo", FeedURL: "http://example.com/foo.xml"},
+		{Title: "bar", FeedURL: "http://example.com/bar.xml?a=1&b=2", Category: "News"},
+		{Title: "baz", FeedURL: "http://example.com/baz.xml", Category: "News/Tech"},
+		{Title: "zed", FeedURL: "http://example.com/zed.xml", Category: "News/Tech"},
+	}
+
+	if !reflect.DeepEqual(subs, want) {
+		t.Errorf("unexpected subscriptions in:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := lib.WriteOPML(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if subs, err := ParseOPML(strings.NewReader(buf.String())); err != nil || len(subs) != 4 || subs[0].Category != "" || subs[3].Category != "" {
+		t.Errorf("unexpected flat export:\n%s", buf.String())
+	}
+}
+
+func TestImportWithoutFetching(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<rss><channel><title>Feed</title><item><title>First</title>` +
+			`<guid>foo-1</guid><enclosure url="https://example.com/foo-1.mp3" type="audio/mpeg" length="1000"/>` +
+			`</item></channel></rss>`))
+	}))
+	defer srv.Close()
+
+	lib, err := NewLibrary(NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := lib.Import([]*Subscription{{Title: "Foo Cast", FeedURL: srv.URL + "/foo.xml"}}, t.TempDir(), 0)
+	if len(results) != 1 || results[0].Err != nil || results[0].Podcast == nil {
+		t.Fatalf("import failed: %+v", results[0])
+	}
+
+	foo := results[0].Podcast
+	if fileExists(foo.LocalStore) {
+		t.Fatal("feed fetched on import")
+	}
+
+	eps, err := foo.NewEpisodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(eps) != 1 || eps[0].Title != "First" || !fileExists(foo.FeedFile()) {
+		t.Errorf("feed not fetched on first update: %v", eps)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"archive/zip"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -22,92 +22,75 @@ const (
 // Podcast represents a podcast. It has a feed URL, name
 // and additional metadata.
 type Podcast struct {
-	FeedURL    string `json:"feed_url"`    // URL to retrieve the podcast feed from
-	Name       string `json:"name"`        // The name under which this podcast is managed
-	LocalStore string `json:"local_store"` // Directory path of the local store for this podcast
+	FeedURL    string      `json:"feed_url"`           // URL to retrieve the podcast feed from
+	Name       string      `json:"name"`               // The name under which this podcast is managed
+	LocalStore string      `json:"local_store"`        // Directory path of the local store for this podcast
+	Settings   PodSettings `json:"settings"`           // Settings of the podcast, overriding the global defaults
+	Category   string      `json:"category,omitempty"` // Category grouping the podcast, like "News/Tech"
+	Source     string      `json:"source,omitempty"`   // URL of the OPML list the podcast is synced with
+
+	lib *Library // Library the podcast is managed in
 }
 
-// New creates a new podcast and intializes the
-// local storage for it. If creation of the local storage
-// fails, or a podcast by that name is already managed by
-// gopodgrab, an error is returned.
-func New(name, feedURL, storageDir string) (*Podcast, error) {
-	if name == ReservedPodName {
-		return nil, ErrReservedName
+// Save stores the podcast in the configuration of its library,
+// replacing the podcast by the same name.
+func (pod *Podcast) Save() error {
+	if pod.lib == nil {
+		return fmt.Errorf("%w: %s", ErrNoLibrary, pod.Name)
 	}
 
-	if podExists(name) {
-		return nil, ErrPodExists
-	}
-
-	pod := &Podcast{
-		Name:       name,
-		FeedURL:    feedURL,
-		LocalStore: storageDir,
-	}
-
-	if err := pod.RefreshFeed(); err != nil {
-		return nil, err
-	}
+	return pod.lib.Save(pod)
+}
 
-	if err := addPod(pod); err != nil {
-		return nil, err
+// defaults returns the settings podcast settings fall back to, the
+// global settings of its library or Defaults.
+func (pod *Podcast) defaults() *PodSettings {
+	if pod.lib != nil {
+		return &pod.lib.settings.PodSettings
 	}
 
-	return pod, nil
+	return Defaults
 }
 
-// List returns the list of managed podcasts from
-// the configuration file.
-// Failure to read the configuration file results in a error.
-func List() ([]*Podcast, error) {
-	pods, err := readPods()
-	if err != nil {
-		return nil, err
-	}
-
-	res := make([]*Podcast, 0, len(pods))
-	for _, p := range pods {
-		res = append(res, p)
+// RefreshFeed updates the locally stored feed from remote.
+// The stored feed is only replaced once the new one has been
+// retrieved completely. Feeds larger than MaxFeedSize are rejected.
+// The new feed is added to the feed history if it changed.
+func (pod *Podcast) RefreshFeed() error {
+	if err := checkURL(pod.FeedURL); err != nil {
+		return err
 	}
 
-	return res, nil
-}
-
-// Get returns a specific podcast from the configuration by name.
-// If the podcast is not found by name, or the configuration file
-// cannot be read, then an error is returned.
-func Get(name string) (*Podcast, error) {
-	pods, err := readPods()
+	resp, err := http.Get(pod.FeedURL)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer resp.Body.Close()
 
-	pod, ok := pods[name]
-	if !ok {
-		return nil, ErrNoEntry
+	if err := checkResponse(resp); err != nil {
+		return err
 	}
 
-	return pod, nil
-}
-
-// RefreshFeed updates the locally stored feed from remote.
-func (pod *Podcast) RefreshFeed() error {
-	resp, err := http.Get(pod.FeedURL)
-	if err != nil {
+	if err := pod.writeFeedFile(resp.Body); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	err = pod.storeExists()
-	if err != nil {
+	return pod.addSnapshot(time.Now())
+}
+
+// writeFeedFile replaces the stored feed with the one read from r,
+// zipped under the name of the podcast. Feeds larger than MaxFeedSize
+// are rejected.
+func (pod *Podcast) writeFeedFile(r io.Reader) error {
+	if err := pod.storeExists(); err != nil {
 		return err
 	}
 
-	f, err := os.Create(pod.FeedFile())
+	f, err := ioutil.TempFile(pod.LocalStore, feedFileName+".*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(f.Name())
 	defer f.Close()
 
 	zipper := zip.NewWriter(f)
@@ -117,7 +100,7 @@ func (pod *Podcast) RefreshFeed() error {
 		return err
 	}
 
-	_, err = io.Copy(file, resp.Body)
+	_, err = limitedCopy(file, r, MaxFeedSize, ErrFeedTooLarge)
 	if err != nil {
 		return err
 	}
@@ -127,53 +110,135 @@ func (pod *Podcast) RefreshFeed() error {
 		return err
 	}
 
-	return nil
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), pod.FeedFile())
+}
+
+// readFeedFile returns the stored feed.
+func (pod *Podcast) readFeedFile() ([]byte, error) {
+	arc, err := zip.OpenReader(pod.FeedFile())
+	if err != nil {
+		return nil, err
+	}
+	defer arc.Close()
+
+	if len(arc.File) < 1 {
+		return nil, ErrArchiveEmpty
+	}
+
+	r, err := arc.File[0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
 }
 
 // NewEpisodes reads the feed and compares the list of episodes in
 // the feed against the one already in the local storage.
-// It returns the difference feed - storage.
+// It returns the difference feed - storage. Every returned episode
+// is assigned a file name that is unique within the local storage.
+// A feed not fetched yet, like that of an imported podcast, is
+// fetched first.
 func (pod *Podcast) NewEpisodes() ([]*Episode, error) {
-	stored, err := pod.readStore()
+	if !fileExists(pod.FeedFile()) {
+		if err := pod.RefreshFeed(); err != nil {
+			return nil, err
+		}
+	}
+
+	_, _, newEpis, err := pod.scanStore()
+	return newEpis, err
+}
+
+// scanStore reads the stored feed and matches its episodes against the
+// local storage. Files in the storage that predate the store index are
+// adopted into it. It returns the episodes of the feed, the updated
+// store index and the episodes not yet in the local storage.
+func (pod *Podcast) scanStore() ([]*Episode, *storeIndex, []*Episode, error) {
+	feedEpis, idx, newEpis, adopted, err := pod.matchStore()
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
+	}
+
+	if adopted {
+		if err := pod.writeIndex(idx); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return feedEpis, idx, newEpis, nil
+}
+
+// matchStore does what scanStore does without writing the store index.
+// It reports whether files were adopted into the returned index.
+func (pod *Podcast) matchStore() ([]*Episode, *storeIndex, []*Episode, bool, error) {
+	files, err := pod.readStore()
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+
+	idx, err := pod.readIndex()
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+
+	feedEpis, err := pod.readFeed()
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+
+	newEpis, adopted, err := pod.assignFilenames(feedEpis, idx, files)
+	if err != nil {
+		return nil, nil, nil, false, err
 	}
 
+	return feedEpis, idx, newEpis, adopted, nil
+}
+
+// readFeed parses the episodes from the locally stored feed.
+func (pod *Podcast) readFeed() ([]*Episode, error) {
+	_, eps, err := pod.readFeedChannel()
+	return eps, err
+}
+
+// readFeedChannel parses the channel metadata and the episodes from
+// the locally stored feed.
+func (pod *Podcast) readFeedChannel() (*Channel, []*Episode, error) {
 	arc, err := zip.OpenReader(pod.FeedFile())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer arc.Close()
 
 	if len(arc.File) < 1 {
-		return nil, ErrArchiveEmpty
+		return nil, nil, ErrArchiveEmpty
 	}
 
 	feed, err := arc.File[0].Open()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer feed.Close()
 
-	feedEpis, err := parseFeed(feed)
+	ch, eps, err := parseFeedChannel(feed)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var newEpis []*Episode
-	for _, e := range feedEpis {
-		if !stored[e.Title] {
-			newEpis = append(newEpis, e)
-		}
-	}
+	pod.selectEnclosures(eps)
 
-	return newEpis, nil
+	return ch, eps, nil
 }
 
 // readStore reads the list of episodes that are in the local
-// storage of the podcast returning a set of filenames without
-// extensions.
-func (pod *Podcast) readStore() (map[string]bool, error) {
+// storage of the podcast. It returns the file names indexed by
+// their lower case name without extension.
+func (pod *Podcast) readStore() (map[string]string, error) {
 	dir, err := os.OpenFile(pod.LocalStore, os.O_RDONLY, os.ModeDir)
 	if err != nil {
 		return nil, err
@@ -184,20 +249,36 @@ func (pod *Podcast) readStore() (map[string]bool, error) {
 		return nil, err
 	}
 
-	stored := make(map[string]bool, len(content))
+	stored := make(map[string]string, len(content))
 
 	for _, e := range content {
-		if e == feedFileName {
+		if !isEpisodeFile(e) {
 			continue
 		}
 
-		e = strings.TrimSuffix(e, filepath.Ext(e))
-		stored[e] = true
+		stored[strings.ToLower(strings.TrimSuffix(e, filepath.Ext(e)))] = e
 	}
 
 	return stored, nil
 }
 
+// isEpisodeFile reports whether the file name in the local store
+// can belong to an episode rather than gopodgrab's bookkeeping or
+// artwork.
+func isEpisodeFile(name string) bool {
+	if isImageFile(name) {
+		return false
+	}
+
+	for _, f := range []string{feedFileName, historyFileName, indexFileName, renameLogFileName, moveJournalFileName} {
+		if name == f || strings.HasPrefix(name, f+".") {
+			return false
+		}
+	}
+
+	return !strings.HasSuffix(name, ".part") && !strings.HasSuffix(name, ".renaming")
+}
+
 // storeExists ensures that the podcast storage directory is present.
 func (pod *Podcast) storeExists() error {
 	if err := os.MkdirAll(pod.LocalStore, os.ModeDir|0755); err != nil {
@@ -224,18 +305,52 @@ func dirExists(path string) bool {
 
 // DownloadEpisodes retrieves all episodes and stores them in the local
 // storage. For each retrieved episode the size in bytes is recorded
-// in Episode.Bytes.
-func (pod *Podcast) DownloadEpisodes(eps []*Episode) error {
+// in Episode.Bytes. The episodes are expected to come from NewEpisodes,
+// which assigns their file names. Each download is recorded in the
+// store index right away. The files are tagged if the podcast's
+// settings say so, or tag is set.
+func (pod *Podcast) DownloadEpisodes(eps []*Episode, tag bool) error {
 	totalEps := len(eps)
 	if totalEps == 0 {
 		return nil
 	}
 
+	idx, err := pod.readIndex()
+	if err != nil {
+		return err
+	}
+
+	var tagger *episodeTagger
+	if tag || pod.Resolved().TagFiles {
+		if tagger, err = pod.newEpisodeTagger(); err != nil {
+			return err
+		}
+	}
+
 	for i, e := range eps {
 		pgb := newProgressBar(e.File.Size)
 		pgb.Describe(fmt.Sprintf("[cyan][%d/%d][reset] %s", i+1, totalEps, e.Title))
 
-		if err := download(e, pod.LocalStore, pgb); err != nil {
+		if e.file == "" {
+			return fmt.Errorf("episode %q has no file name assigned", e.Title)
+		}
+
+		path, err := safeJoin(pod.LocalStore, e.file)
+		if err != nil {
+			return err
+		}
+
+		if err := download(e, path, pgb); err != nil {
+			return err
+		}
+
+		if tagger != nil {
+			tagger.tag(e, path)
+		}
+
+		idx.record(e, e.file, time.Now())
+
+		if err := pod.writeIndex(idx); err != nil {
 			return err
 		}
 	}
@@ -245,26 +360,41 @@ func (pod *Podcast) DownloadEpisodes(eps []*Episode) error {
 	return nil
 }
 
-// download downloads Episode e to the directory dir. It accepts an
+// download downloads Episode e to the file at path. It accepts an
 // optional progressbar to display the progress while downloading.
-func download(e *Episode, dir string, pgb *progressbar.ProgressBar) error {
-	u, err := url.Parse(e.File.URL)
-	if err != nil {
+// The episode is written to a temporary file next to path first, which
+// is removed if the download fails or runs far past the declared length.
+func download(e *Episode, path string, pgb *progressbar.ProgressBar) error {
+	if e.File == nil {
+		return fmt.Errorf("episode %q has no enclosure", e.Title)
+	}
+
+	if err := checkURL(e.File.URL); err != nil {
 		return err
 	}
 
-	resp, err := http.Get(u.String())
+	resp, err := http.Get(e.File.URL)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	ext := filepath.Ext(u.Path)
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	limit := downloadLimit(e.File.Size)
+	if resp.ContentLength > limit {
+		return fmt.Errorf("%w: server announced %d bytes", ErrDownloadOverrun, resp.ContentLength)
+	}
 
-	f, err := os.Create(filepath.Join(dir, e.Title+ext))
+	part := path + ".part"
+
+	f, err := os.Create(part)
 	if err != nil {
 		return err
 	}
+	defer os.Remove(part)
 	defer f.Close()
 
 	var w io.Writer = f
@@ -273,12 +403,20 @@ func download(e *Episode, dir string, pgb *progressbar.ProgressBar) error {
 		w = io.MultiWriter(f, pgb)
 	}
 
-	n, err := io.Copy(w, resp.Body)
+	n, err := limitedCopy(w, resp.Body, limit, ErrDownloadOverrun)
 	if err != nil {
 		return err
 	}
 	e.Bytes = n
 
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(part, path); err != nil {
+		return err
+	}
+
 	fmt.Println()
 
 	return nil
@@ -0,0 +1,99 @@
+package pod
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StoreFiles lists the files in the local store of the podcast,
+// relative to it, along with their total size in bytes.
+func (pod *Podcast) StoreFiles() ([]string, int64, error) {
+	var files []string
+	var size int64
+
+	err := filepath.Walk(pod.LocalStore, func(path string, info os.FileInfo, err error) error {
+		if path == pod.LocalStore && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(pod.LocalStore, path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, rel)
+		size += info.Size()
+
+		return nil
+	})
+
+	return files, size, err
+}
+
+// Purge removes the podcasts by those names from the library, as Remove
+// does, and deletes their local stores with all files in them. Local
+// stores shared with other podcasts of the library, or containing or
+// inside the one of another podcast, result in ErrSharedStore before
+// anything is removed.
+func (lib *Library) Purge(names ...string) error {
+	purged := make(map[string]bool, len(names))
+	for _, name := range names {
+		purged[name] = true
+	}
+
+	var dirs []string
+
+	for _, name := range names {
+		pod, err := lib.Get(name)
+		if err != nil {
+			return err
+		}
+
+		dir, err := filepath.Abs(pod.LocalStore)
+		if err != nil {
+			return err
+		}
+
+		if pod.LocalStore == "" || filepath.Dir(dir) == dir {
+			return fmt.Errorf("%w: %s has no storage directory of its own", ErrSharedStore, name)
+		}
+
+		for _, other := range lib.pods {
+			if purged[other.Name] {
+				continue
+			}
+
+			if otherDir, err := filepath.Abs(other.LocalStore); err == nil && (within(otherDir, dir) || within(dir, otherDir)) {
+				return fmt.Errorf("%w: %s with %s", ErrSharedStore, name, other.Name)
+			}
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	if err := lib.Remove(names...); err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// within reports whether path is dir or inside of it.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,81 @@
+package pod
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestPurge(t *testing.T) {
+	dir := t.TempDir()
+
+	lib, err := NewLibrary(NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pods := []*Podcast{
+		{Name: "foo", LocalStore: filepath.Join(dir, "foo")},
+		{Name: "bar", LocalStore: filepath.Join(dir, "bar")},
+		{Name: "nested", LocalStore: filepath.Join(dir, "bar", "nested")},
+		{Name: "baz", LocalStore: filepath.Join(dir, "baz")},
+	}
+
+	for _, p := range pods {
+		if err := lib.Add(p); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := p.storeExists(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(p.LocalStore, "episode.mp3"), []byte("mp3"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := lib.Save(pods...); err != nil {
+		t.Fatal(err)
+	}
+
+	files, size, err := pods[1].StoreFiles()
+	if err != nil || len(files) != 2 || size != 6 {
+		t.Errorf("got files %v of %d bytes, %v", files, size, err)
+	}
+
+	if err := lib.Purge("bar"); !errors.Is(err, ErrSharedStore) {
+		t.Errorf("expected ErrSharedStore, got %v", err)
+	}
+
+	if !lib.Has("bar") || !dirExists(pods[1].LocalStore) {
+		t.Error("shared podcast storage removed")
+	}
+
+	if err := lib.Purge("bar", "nested", "foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lib.Remove("baz"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range pods[:3] {
+		if dirExists(p.LocalStore) {
+			t.Errorf("%s: storage not purged", p.Name)
+		}
+	}
+
+	if !fileExists(filepath.Join(dir, "baz", "episode.mp3")) {
+		t.Error("removing without purging deleted files")
+	}
+
+	if err := lib.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(lib.Podcasts()); n != 0 {
+		t.Errorf("%d podcasts left", n)
+	}
+}
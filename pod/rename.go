@@ -0,0 +1,381 @@
+package pod
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const renameLogFileName = "renames.json"
+
+// Rename is the renaming of a single episode file in the local store.
+type Rename struct {
+	Title string `json:"title"` // Title of the episode the file belongs to
+	From  string `json:"from"`  // Current file name
+	To    string `json:"to"`    // New file name
+
+	key string
+}
+
+// renameBatch is a set of renames applied together.
+type renameBatch struct {
+	Time    time.Time `json:"time"`
+	Renames []*Rename `json:"renames"`
+}
+
+// PlanRenames matches the files in the local store to the episodes of
+// the stored feed and works out their names under the current naming
+// rules. Files are matched through the store index, or, if they predate
+// it, by the name the current template or any of the fromTemplates
+// would give them. Only files whose name changes are returned.
+func (pod *Podcast) PlanRenames(fromTemplates ...string) ([]*Rename, error) {
+	files, err := pod.readStore()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := pod.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	eps, err := pod.readFeed()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tmpl := range fromTemplates {
+		if err := ValidateFilenameTemplate(tmpl); err != nil {
+			return nil, err
+		}
+	}
+
+	present := make(map[string]string, len(files))
+	for _, file := range files {
+		present[strings.ToLower(file)] = file
+	}
+
+	owned := make(map[*Episode]string)
+	claimed := make(map[string]bool)
+	unmatched := make(map[string]*Episode)
+
+	for _, e := range eps {
+		if e.File == nil {
+			continue
+		}
+
+		key := episodeKey(e)
+		if _, ok := unmatched[key]; ok {
+			continue
+		}
+
+		if se, ok := idx.Episodes[key]; ok {
+			if file, ok := present[strings.ToLower(se.File)]; ok {
+				owned[e] = file
+				claimed[strings.ToLower(file)] = true
+			}
+
+			unmatched[key] = nil
+			continue
+		}
+
+		unmatched[key] = e
+	}
+
+	templates := append([]string{pod.filenameTemplate()}, fromTemplates...)
+
+	for _, tmpl := range templates {
+		other := *pod
+		other.Settings.FilenameTemplate = tmpl
+		claims := make(map[string][]*Episode)
+
+		for _, e := range eps {
+			if e.File == nil || unmatched[episodeKey(e)] != e {
+				continue
+			}
+
+			name, err := other.episodeFilename(e)
+			if err != nil {
+				return nil, err
+			}
+
+			base := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+			claims[base] = append(claims[base], e)
+		}
+
+		for base, claimants := range claims {
+			file, ok := files[base]
+			if !ok || claimed[strings.ToLower(file)] {
+				continue
+			}
+
+			e := likeliestOwner(claimants, filepath.Join(pod.LocalStore, file))
+			owned[e] = file
+			claimed[strings.ToLower(file)] = true
+			unmatched[episodeKey(e)] = nil
+		}
+	}
+
+	// Names of files which stay where they are can't be used as targets.
+	used := make(map[string]bool, len(files))
+	for name := range present {
+		if !claimed[name] {
+			used[name] = true
+		}
+	}
+
+	// Files already named by the current rules keep their name. This
+	// includes names made unique by a suffix.
+	targets := make(map[*Episode]string, len(owned))
+
+	for _, e := range eps {
+		file, ok := owned[e]
+		if !ok {
+			continue
+		}
+
+		name, err := pod.episodeFilename(e)
+		if err != nil {
+			return nil, err
+		}
+
+		if name == file || sameSuffixed(name, file) {
+			used[strings.ToLower(file)] = true
+			continue
+		}
+
+		targets[e] = name
+	}
+
+	var renames []*Rename
+
+	for _, e := range eps {
+		name, ok := targets[e]
+		if !ok {
+			continue
+		}
+
+		name = uniqueFilename(e, name, func(n string) bool {
+			return used[strings.ToLower(n)]
+		})
+		used[strings.ToLower(name)] = true
+
+		renames = append(renames, &Rename{Title: e.Title, From: owned[e], To: name, key: episodeKey(e)})
+	}
+
+	sort.Slice(renames, func(i, j int) bool {
+		return renames[i].From < renames[j].From
+	})
+
+	return renames, nil
+}
+
+// sameSuffixed reports whether file is name with a suffix added by
+// uniqueFilename.
+func sameSuffixed(name, file string) bool {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	if !strings.HasSuffix(file, ext) || !strings.HasPrefix(file, base+" ") {
+		return false
+	}
+
+	suffix := strings.TrimSuffix(strings.TrimPrefix(file, base+" "), ext)
+
+	return strings.HasPrefix(suffix, "(") && strings.HasSuffix(suffix, ")") ||
+		strings.HasPrefix(suffix, "[") && strings.HasSuffix(suffix, "]")
+}
+
+// ApplyRenames renames the files in the local store as planned by
+// PlanRenames and records the new names in the store index. The batch
+// is added to the rename log first, so it can be reverted by UndoRenames.
+func (pod *Podcast) ApplyRenames(renames []*Rename) error {
+	if len(renames) == 0 {
+		return nil
+	}
+
+	log, err := pod.readRenameLog()
+	if err != nil {
+		return err
+	}
+
+	log = append(log, &renameBatch{Time: time.Now(), Renames: renames})
+	if err := pod.writeRenameLog(log); err != nil {
+		return err
+	}
+
+	if err := pod.renameFiles(renames, false); err != nil {
+		return err
+	}
+
+	idx, err := pod.readIndex()
+	if err != nil {
+		return err
+	}
+
+	eps, err := pod.readFeed()
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string]*Episode, len(eps))
+	for _, e := range eps {
+		byKey[episodeKey(e)] = e
+	}
+
+	for _, r := range renames {
+		if se, ok := idx.Episodes[r.key]; ok {
+			se.File = r.To
+			continue
+		}
+
+		if e, ok := byKey[r.key]; ok {
+			idx.record(e, r.To, time.Time{})
+		}
+	}
+
+	return pod.writeIndex(idx)
+}
+
+// UndoRenames reverts the most recent batch of renames from the rename
+// log. It returns the reverted renames, none if the log is empty.
+func (pod *Podcast) UndoRenames() ([]*Rename, error) {
+	log, err := pod.readRenameLog()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(log) == 0 {
+		return nil, nil
+	}
+
+	last := log[len(log)-1]
+
+	if err := pod.renameFiles(last.Renames, true); err != nil {
+		return nil, err
+	}
+
+	idx, err := pod.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := make(map[string]string, len(last.Renames))
+	for _, r := range last.Renames {
+		reverted[r.To] = r.From
+	}
+
+	for _, se := range idx.Episodes {
+		if from, ok := reverted[se.File]; ok {
+			se.File = from
+		}
+	}
+
+	if err := pod.writeIndex(idx); err != nil {
+		return nil, err
+	}
+
+	return last.Renames, pod.writeRenameLog(log[:len(log)-1])
+}
+
+// renameFiles performs the renames, in reverse if undo is set. Files
+// are moved to a temporary name first, so renames may swap names or
+// form chains. Renames already carried out are skipped, which allows
+// continuing after an interruption.
+func (pod *Podcast) renameFiles(renames []*Rename, undo bool) error {
+	type move struct{ from, tmp, to string }
+
+	moves := make([]move, 0, len(renames))
+
+	for _, r := range renames {
+		from, to := r.From, r.To
+		if undo {
+			from, to = to, from
+		}
+
+		src, err := safeJoin(pod.LocalStore, from)
+		if err != nil {
+			return err
+		}
+
+		dst, err := safeJoin(pod.LocalStore, to)
+		if err != nil {
+			return err
+		}
+
+		moves = append(moves, move{from: src, tmp: src + ".renaming", to: dst})
+	}
+
+	for _, m := range moves {
+		err := os.Rename(m.from, m.tmp)
+		if errors.Is(err, os.ErrNotExist) && fileExists(m.tmp) {
+			continue
+		}
+
+		if errors.Is(err, os.ErrNotExist) && fileExists(m.to) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, m := range moves {
+		if !fileExists(m.tmp) && fileExists(m.to) {
+			continue
+		}
+
+		if err := os.Rename(m.tmp, m.to); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renameLogFile returns the full file path of the rename log.
+func (pod *Podcast) renameLogFile() string {
+	return filepath.Join(pod.LocalStore, renameLogFileName)
+}
+
+// readRenameLog reads the batches of renames applied to the local store.
+func (pod *Podcast) readRenameLog() ([]*renameBatch, error) {
+	buf, err := ioutil.ReadFile(pod.renameLogFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var log []*renameBatch
+	if err := json.Unmarshal(buf, &log); err != nil {
+		return nil, fmt.Errorf("%s: %w", pod.renameLogFile(), err)
+	}
+
+	return log, nil
+}
+
+// writeRenameLog replaces the rename log of the local store.
+func (pod *Podcast) writeRenameLog(log []*renameBatch) error {
+	buf, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(pod.renameLogFile(), buf, 0644)
+}
+
+// fileExists checks whether a file exists at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
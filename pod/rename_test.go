@@ -0,0 +1,150 @@
+package pod
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// fixtureStore creates a local store holding testdata/feed as the
+// stored feed and the given episode files.
+func fixtureStore(t *testing.T, feed string, files map[string]int) *Podcast {
+	t.Helper()
+
+	p := &Podcast{Name: "foocast", LocalStore: t.TempDir()}
+
+	buf, err := ioutil.ReadFile(filepath.Join("testdata", feed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(p.FeedFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zipper := zip.NewWriter(f)
+
+	w, err := zipper.Create(p.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zipper.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, size := range files {
+		if err := ioutil.WriteFile(filepath.Join(p.LocalStore, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return p
+}
+
+// storeFiles lists the episode files in the local store of p.
+func storeFiles(t *testing.T, p *Podcast) []string {
+	t.Helper()
+
+	files, err := p.readStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := make([]string, 0, len(files))
+	for _, f := range files {
+		res = append(res, f)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func TestRenameFiles(t *testing.T) {
+	p := fixtureStore(t, "feed.xml", map[string]int{
+		"Bonus.mp3":   1000,
+		"Second.mp3":  2000,
+		"Unknown.mp3": 42,
+	})
+
+	p.Settings.FilenameTemplate = `{{.Season}}x{{printf "%02d" .Number}} {{.Title}}{{.Ext}}`
+
+	renames, err := p.PlanRenames(DefaultFilenameTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(renames) != 2 {
+		t.Fatalf("expected 2 renames, got %d", len(renames))
+	}
+
+	if err := p.ApplyRenames(renames); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"1x01 Bonus.mp3", "1x02 Second.mp3", "Unknown.mp3"}
+	if res := storeFiles(t, p); !equalStrings(res, expected) {
+		t.Errorf("after renaming got %v, expected %v", res, expected)
+	}
+
+	// The renamed files are known by their new name.
+	newEpis, err := p.NewEpisodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(newEpis) != 1 || newEpis[0].GUID != "foo-3" {
+		t.Errorf("expected only foo-3 to be new, got %v", newEpis)
+	}
+
+	// Nothing left to do on a second run.
+	if renames, err := p.PlanRenames(DefaultFilenameTemplate); err != nil || len(renames) != 0 {
+		t.Errorf("expected no further renames, got %v, %v", renames, err)
+	}
+
+	reverted, err := p.UndoRenames()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reverted) != 2 {
+		t.Errorf("expected 2 reverted renames, got %d", len(reverted))
+	}
+
+	expected = []string{"Bonus.mp3", "Second.mp3", "Unknown.mp3"}
+	if res := storeFiles(t, p); !equalStrings(res, expected) {
+		t.Errorf("after undo got %v, expected %v", res, expected)
+	}
+
+	idx, err := p.readIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if se := idx.Episodes["foo-1"]; se == nil || se.File != "Bonus.mp3" {
+		t.Errorf("expected the index to be reverted, got %+v", se)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,365 @@
+package pod
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retention holds the rules which downloaded episodes of a podcast
+// are kept. An episode is pruned as soon as it breaks any rule, unless
+// it is pinned. Rules left empty don't apply.
+type Retention struct {
+	KeepLast *int     `json:"keep_last,omitempty"` // Number of most recent episodes to keep, 0 for all
+	MaxAge   string   `json:"max_age,omitempty"`   // Maximum age of episodes, e.g. "90d"
+	MaxSize  string   `json:"max_size,omitempty"`  // Maximum total size of episodes, e.g. "20GB"
+	Pinned   []string `json:"pinned,omitempty"`    // Keys of episodes that are kept forever
+}
+
+// Validate checks the retention rules for errors.
+func (r *Retention) Validate() error {
+	if r.KeepLast != nil && *r.KeepLast < 0 {
+		return fmt.Errorf("%w: negative number of episodes to keep", ErrInvalidRetention)
+	}
+
+	if _, err := ParseAge(r.MaxAge); err != nil {
+		return err
+	}
+
+	if _, err := ParseSize(r.MaxSize); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// KeepLastCount returns the number of most recent episodes to keep, 0
+// if the rule is unset.
+func (r *Retention) KeepLastCount() int {
+	if r.KeepLast == nil {
+		return 0
+	}
+
+	return *r.KeepLast
+}
+
+// IsPinned reports whether the episode with the given key is pinned.
+func (r *Retention) IsPinned(key string) bool {
+	for _, p := range r.Pinned {
+		if p == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseAge parses an age like "36h", "90d" or "4w". Besides the units
+// understood by time.ParseDuration it accepts d for days and w for
+// weeks. An empty string yields zero.
+func ParseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	days := map[string]int{"d": 1, "w": 7}
+
+	for unit, n := range days {
+		if !strings.HasSuffix(s, unit) {
+			continue
+		}
+
+		num, err := strconv.Atoi(strings.TrimSuffix(s, unit))
+		if err != nil || num < 0 {
+			return 0, fmt.Errorf("%w: age %q", ErrInvalidRetention, s)
+		}
+
+		return time.Duration(num*n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: age %q", ErrInvalidRetention, s)
+	}
+
+	return d, nil
+}
+
+// ParseSize parses a size like "500MB" or "20GB" into bytes. Units are
+// B, KB, MB, GB and TB, all multiples of 1024. An empty string
+// yields zero.
+func ParseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+	}
+
+	num := strings.ToUpper(strings.TrimSpace(s))
+	factor := int64(1)
+
+	for _, u := range units {
+		if strings.HasSuffix(num, u.suffix) {
+			num = strings.TrimSpace(strings.TrimSuffix(num, u.suffix))
+			factor = u.factor
+			break
+		}
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("%w: size %q", ErrInvalidRetention, s)
+	}
+
+	return int64(f * float64(factor)), nil
+}
+
+// Prunable is a downloaded episode that breaks a retention rule.
+type Prunable struct {
+	Title     string    // Title of the episode
+	File      string    // Name of the episode file in the local store
+	Size      int64     // Size of the file in bytes
+	Published time.Time // Publishing date of the episode
+	Reason    string    // The rule the episode breaks
+
+	key string
+}
+
+// PlanPrune applies the retention rules of the podcast to the episodes
+// in the local store and returns the ones to remove, oldest first.
+// Episodes are sorted by publishing date, newest first. The newest
+// KeepLast episodes are kept, those older than MaxAge removed and while
+// the total size is beyond MaxSize the oldest ones are removed.
+func (pod *Podcast) PlanPrune(now time.Time) ([]*Prunable, error) {
+	ret := pod.Resolved().Retention
+	if ret == nil {
+		return nil, nil
+	}
+
+	keepLast := ret.KeepLastCount()
+
+	maxAge, err := ParseAge(ret.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	maxSize, err := ParseSize(ret.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	_, idx, _, err := pod.scanStore()
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []*Prunable
+	var total int64
+
+	for key, se := range idx.Episodes {
+		if !se.Pruned.IsZero() {
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(pod.LocalStore, se.File))
+		if err != nil {
+			continue
+		}
+
+		published := se.Published
+		if published.IsZero() {
+			published = se.Downloaded
+		}
+
+		if published.IsZero() {
+			published = info.ModTime()
+		}
+
+		stored = append(stored, &Prunable{
+			Title:     se.Title,
+			File:      se.File,
+			Size:      info.Size(),
+			Published: published,
+			key:       key,
+		})
+		total += info.Size()
+	}
+
+	sort.Slice(stored, func(i, j int) bool {
+		if stored[i].Published.Equal(stored[j].Published) {
+			return stored[i].File < stored[j].File
+		}
+
+		return stored[i].Published.After(stored[j].Published)
+	})
+
+	var prune []*Prunable
+	kept := 0
+
+	for _, p := range stored {
+		if ret.IsPinned(p.key) {
+			continue
+		}
+
+		switch {
+		case keepLast > 0 && kept >= keepLast:
+			p.Reason = fmt.Sprintf("beyond the last %d episodes", keepLast)
+		case maxAge > 0 && now.Sub(p.Published) > maxAge:
+			p.Reason = "older than " + ret.MaxAge
+		default:
+			kept++
+			continue
+		}
+
+		prune = append(prune, p)
+		total -= p.Size
+	}
+
+	for i := len(stored) - 1; i >= 0 && maxSize > 0 && total > maxSize; i-- {
+		p := stored[i]
+		if p.Reason != "" || ret.IsPinned(p.key) {
+			continue
+		}
+
+		p.Reason = "total size beyond " + ret.MaxSize
+		prune = append(prune, p)
+		total -= p.Size
+	}
+
+	sort.Slice(prune, func(i, j int) bool {
+		return prune[i].Published.Before(prune[j].Published)
+	})
+
+	return prune, nil
+}
+
+// Prune removes the episode files planned by PlanPrune. If trashDir is
+// set, the files are moved to a directory named after the podcast in
+// trashDir instead of being deleted. Pruned episodes stay in the store
+// index, so they are not downloaded again.
+func (pod *Podcast) Prune(prune []*Prunable, trashDir string) error {
+	if len(prune) == 0 {
+		return nil
+	}
+
+	idx, err := pod.readIndex()
+	if err != nil {
+		return err
+	}
+
+	trash := ""
+	if trashDir != "" {
+		trash = filepath.Join(trashDir, sanitizeFilename(pod.Name))
+		if err := os.MkdirAll(trash, 0755); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range prune {
+		path, err := safeJoin(pod.LocalStore, p.File)
+		if err != nil {
+			return err
+		}
+
+		if trash != "" {
+			err = moveFile(path, trashPath(trash, p.File))
+		} else {
+			err = os.Remove(path)
+		}
+
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if se, ok := idx.Episodes[p.key]; ok {
+			se.Pruned = time.Now()
+
+			if se.Image != nil {
+				pod.removeImage(se.Image)
+				se.Image = nil
+			}
+		}
+
+		if err := pod.writeIndex(idx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// trashPath returns a path for file inside of dir that isn't used yet.
+func trashPath(dir, file string) string {
+	path := filepath.Join(dir, file)
+
+	for i := 2; fileExists(path); i++ {
+		path = filepath.Join(dir, withSuffix(file, fmt.Sprintf(" (%d)", i)))
+	}
+
+	return path
+}
+
+// Pin pins the episodes matching ref, which is an episode's GUID,
+// title or file name. Only downloaded episodes can be pinned. It
+// returns the titles of the pinned episodes.
+func (pod *Podcast) Pin(ref string) ([]string, error) {
+	return pod.pin(ref, true)
+}
+
+// Unpin removes the pin from the episodes matching ref, see Pin.
+func (pod *Podcast) Unpin(ref string) ([]string, error) {
+	return pod.pin(ref, false)
+}
+
+func (pod *Podcast) pin(ref string, pin bool) ([]string, error) {
+	idx, err := pod.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	if pod.Settings.Retention == nil {
+		pod.Settings.Retention = &Retention{}
+	}
+
+	var titles []string
+
+	for key, se := range idx.Episodes {
+		if key != ref && !strings.EqualFold(se.Title, ref) && !strings.EqualFold(se.File, ref) {
+			continue
+		}
+
+		titles = append(titles, se.Title)
+
+		if pin && !pod.Settings.Retention.IsPinned(key) {
+			pod.Settings.Retention.Pinned = append(pod.Settings.Retention.Pinned, key)
+		}
+
+		if !pin {
+			pinned := pod.Settings.Retention.Pinned[:0]
+			for _, p := range pod.Settings.Retention.Pinned {
+				if p != key {
+					pinned = append(pinned, p)
+				}
+			}
+			pod.Settings.Retention.Pinned = pinned
+		}
+	}
+
+	if len(titles) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrNoEpisode, ref)
+	}
+
+	sort.Strings(titles)
+
+	return titles, nil
+}
@@ -0,0 +1,139 @@
+package pod
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]struct {
+		in       string
+		expected int64
+		fail     bool
+	}{
+		"Empty":      {in: "", expected: 0},
+		"Bytes":      {in: "512", expected: 512},
+		"Kilo":       {in: "2KB", expected: 2048},
+		"Lower case": {in: "1.5gb", expected: 3 << 29},
+		"Spaced":     {in: "10 MB", expected: 10 << 20},
+		"Negative":   {in: "-1GB", fail: true},
+		"Garbage":    {in: "lots", fail: true},
+	}
+
+	for name, test := range tests {
+		res, err := ParseSize(test.in)
+		if test.fail != (err != nil) {
+			t.Errorf("%s: unexpected error state for %q: %v", name, test.in, err)
+			continue
+		}
+
+		if res != test.expected {
+			t.Errorf("%s: for %q got %d, but expected %d", name, test.in, res, test.expected)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]struct {
+		in       string
+		expected time.Duration
+		fail     bool
+	}{
+		"Empty":    {in: "", expected: 0},
+		"Hours":    {in: "36h", expected: 36 * time.Hour},
+		"Days":     {in: "90d", expected: 90 * 24 * time.Hour},
+		"Weeks":    {in: "2w", expected: 14 * 24 * time.Hour},
+		"Negative": {in: "-3d", fail: true},
+		"Garbage":  {in: "forever", fail: true},
+	}
+
+	for name, test := range tests {
+		res, err := ParseAge(test.in)
+		if test.fail != (err != nil) {
+			t.Errorf("%s: unexpected error state for %q: %v", name, test.in, err)
+			continue
+		}
+
+		if res != test.expected {
+			t.Errorf("%s: for %q got %v, but expected %v", name, test.in, res, test.expected)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC)
+	one := 1
+
+	tests := map[string]struct {
+		ret      Retention
+		expected []string
+	}{
+		"No rules":  {ret: Retention{}, expected: nil},
+		"Keep last": {ret: Retention{KeepLast: &one}, expected: []string{"Bonus.mp3", "Second.mp3"}},
+		"Max age":   {ret: Retention{MaxAge: "8d"}, expected: []string{"Bonus.mp3"}},
+		"Max size":  {ret: Retention{MaxSize: "5000B"}, expected: []string{"Bonus.mp3"}},
+		"Pinned":    {ret: Retention{KeepLast: &one, Pinned: []string{"foo-1"}}, expected: []string{"Second.mp3"}},
+	}
+
+	for name, test := range tests {
+		p := fixtureStore(t, "feed.xml", map[string]int{
+			"Bonus.mp3":  1000,
+			"Second.mp3": 2000,
+		})
+
+		// Download the remaining episode like an update would.
+		newEpis, err := p.NewEpisodes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		idx, err := p.readIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, e := range newEpis {
+			if err := ioutil.WriteFile(filepath.Join(p.LocalStore, e.file), make([]byte, e.File.Size), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			idx.record(e, e.file, now)
+		}
+
+		if err := p.writeIndex(idx); err != nil {
+			t.Fatal(err)
+		}
+
+		ret := test.ret
+		p.Settings.Retention = &ret
+
+		prune, err := p.PlanPrune(now)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		var files []string
+		for _, e := range prune {
+			files = append(files, e.File)
+		}
+
+		if !equalStrings(files, test.expected) {
+			t.Errorf("%s: got %v, but expected %v", name, files, test.expected)
+		}
+
+		if err := p.Prune(prune, ""); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		newEpis, err = p.NewEpisodes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(newEpis) != 0 {
+			t.Errorf("%s: pruned episodes are new again: %v", name, newEpis)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package pod
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// MaxFeedSize is the maximum size in bytes of a feed document.
+	MaxFeedSize = 64 << 20
+
+	// maxXMLDepth is the maximum nesting depth of elements in a feed.
+	// Real feeds rarely go deeper than five or six levels.
+	maxXMLDepth = 64
+
+	// MaxEpisodeSize is the maximum size in bytes of a single episode
+	// whose feed entry doesn't declare a plausible length.
+	MaxEpisodeSize = 4 << 30
+
+	// minDeclaredSize is the smallest declared enclosure length that
+	// is taken at face value. Many feeds use 0 or 1 as a placeholder.
+	minDeclaredSize = 64 << 10
+
+	// overrunSlack is the number of bytes a download may run past
+	// twice its declared length before it is aborted.
+	overrunSlack = 16 << 20
+)
+
+// AllowedSchemes holds the URL schemes gopodgrab fetches feeds and
+// episodes from. Further schemes have to be allowed explicitly.
+var AllowedSchemes = map[string]bool{
+	"http":  true,
+	"https": true,
+}
+
+// checkURL verifies that raw is an absolute URL with an allowed scheme.
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	if !AllowedSchemes[strings.ToLower(u.Scheme)] {
+		return fmt.Errorf("%w: %q in %s", ErrSchemeNotAllowed, u.Scheme, raw)
+	}
+
+	return nil
+}
+
+// checkResponse turns unsuccessful HTTP responses into an error.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %s from %s", ErrHTTPStatus, resp.Status, resp.Request.URL)
+	}
+
+	return nil
+}
+
+// safeJoin joins the file name to dir and ensures the result stays
+// inside of dir.
+func safeJoin(dir, name string) (string, error) {
+	p := filepath.Join(dir, name)
+
+	rel, err := filepath.Rel(filepath.Clean(dir), p)
+	if err != nil {
+		return "", err
+	}
+
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) ||
+		filepath.IsAbs(rel) {
+		return "", fmt.Errorf("%w: %q in %s", ErrUnsafePath, name, dir)
+	}
+
+	return p, nil
+}
+
+// downloadLimit returns the number of bytes a download of an enclosure
+// with the declared size may take before it is aborted.
+func downloadLimit(declared int64) int64 {
+	if declared < minDeclaredSize {
+		return MaxEpisodeSize
+	}
+
+	return 2*declared + overrunSlack
+}
+
+// limitedCopy copies from src to dst failing with err once more
+// than limit bytes are read.
+func limitedCopy(dst io.Writer, src io.Reader, limit int64, err error) (int64, error) {
+	n, cerr := io.Copy(dst, io.LimitReader(src, limit+1))
+	if cerr != nil {
+		return n, cerr
+	}
+
+	if n > limit {
+		return n, fmt.Errorf("%w: more than %d bytes", err, limit)
+	}
+
+	return n, nil
+}
+
+// depthLimiter is a token reader that fails once elements are nested
+// deeper than max. The failure is permanent.
+type depthLimiter struct {
+	dec   *xml.Decoder
+	depth int
+	max   int
+	err   error
+}
+
+func (d *depthLimiter) Token() (xml.Token, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	tok, err := d.dec.Token()
+	if err != nil {
+		return tok, err
+	}
+
+	switch tok.(type) {
+	case xml.StartElement:
+		d.depth++
+		if d.depth > d.max {
+			d.err = fmt.Errorf("%w: more than %d levels", ErrFeedTooDeep, d.max)
+			return nil, d.err
+		}
+	case xml.EndElement:
+		d.depth--
+	}
+
+	return tok, nil
+}
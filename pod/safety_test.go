@@ -0,0 +1,187 @@
+package pod
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseFixture parses the feed in testdata/name.
+func parseFixture(t *testing.T, name string) []*Episode {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	eps, err := parseFeed(f)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", name, err)
+	}
+
+	return eps
+}
+
+func TestHostileTitlesStayInStore(t *testing.T) {
+	dir := t.TempDir()
+	p := &Podcast{Name: "hostile", LocalStore: filepath.Join(dir, "store")}
+
+	eps := parseFixture(t, "traversal.xml")
+	if len(eps) != 8 {
+		t.Fatalf("got %d episodes, expected 8", len(eps))
+	}
+
+	for _, e := range eps {
+		name, err := p.episodeFilename(e)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", e.Title, err)
+			continue
+		}
+
+		if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			t.Errorf("%q: unsafe file name %q", e.Title, name)
+		}
+
+		path, err := safeJoin(p.LocalStore, name)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", e.Title, err)
+			continue
+		}
+
+		if filepath.Dir(path) != p.LocalStore {
+			t.Errorf("%q: %s escapes %s", e.Title, path, p.LocalStore)
+		}
+	}
+}
+
+func TestSafeJoin(t *testing.T) {
+	dir := filepath.Join("srv", "pods", "foocast")
+
+	tests := map[string]struct {
+		in   string
+		fail bool
+	}{
+		"Plain name":       {in: "episode.mp3"},
+		"Dots inside":      {in: "a..b.mp3"},
+		"Parent directory": {in: "..", fail: true},
+		"Traversal":        {in: filepath.Join("..", "..", ".bashrc"), fail: true},
+		"Current dir":      {in: ".", fail: true},
+		"Empty":            {in: "", fail: true},
+		"Sibling":          {in: filepath.Join("..", "foocast2", "x"), fail: true},
+	}
+
+	for name, test := range tests {
+		_, err := safeJoin(dir, test.in)
+		if test.fail && !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("%s: expected ErrUnsafePath for %q, got %v", name, test.in, err)
+		}
+
+		if !test.fail && err != nil {
+			t.Errorf("%s: unexpected error for %q: %v", name, test.in, err)
+		}
+	}
+}
+
+func TestDisallowedSchemes(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, e := range parseFixture(t, "schemes.xml") {
+		err := download(e, filepath.Join(dir, "episode"), nil)
+		if !errors.Is(err, ErrSchemeNotAllowed) {
+			t.Errorf("%q: expected ErrSchemeNotAllowed for %s, got %v", e.Title, e.File.URL, err)
+		}
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no files to be written, found %d", len(entries))
+	}
+}
+
+func TestFeedTooDeep(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "deep.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := parseFeed(f); !errors.Is(err, ErrFeedTooDeep) {
+		t.Errorf("expected ErrFeedTooDeep, got %v", err)
+	}
+}
+
+func TestFeedTooLarge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "<rss><channel>")
+		_, _ = io.CopyN(w, zeros{}, MaxFeedSize)
+	}))
+	defer srv.Close()
+
+	p := &Podcast{Name: "huge", FeedURL: srv.URL, LocalStore: t.TempDir()}
+
+	if err := p.RefreshFeed(); !errors.Is(err, ErrFeedTooLarge) {
+		t.Fatalf("expected ErrFeedTooLarge, got %v", err)
+	}
+
+	entries, err := os.ReadDir(p.LocalStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("expected no feed file to be left behind, found %s", entries[0].Name())
+	}
+}
+
+func TestDownloadOverrun(t *testing.T) {
+	const declared = 1 << 20
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.CopyN(w, zeros{}, downloadLimit(declared)+1)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "episode.mp3")
+	e := &Episode{Title: "Endless", File: &podFile{URL: srv.URL + "/episode.mp3", Size: declared}}
+
+	if err := download(e, path, nil); !errors.Is(err, ErrDownloadOverrun) {
+		t.Fatalf("expected ErrDownloadOverrun, got %v", err)
+	}
+
+	for _, p := range []string{path, path + ".part"} {
+		if _, err := os.Stat(p); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected %s to be removed, got %v", p, err)
+		}
+	}
+}
+
+func TestDownloadOverrunAnnounced(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "107374182400")
+	}))
+	defer srv.Close()
+
+	e := &Episode{Title: "Huge", File: &podFile{URL: srv.URL, Size: 1 << 20}}
+
+	err := download(e, filepath.Join(t.TempDir(), "episode.mp3"), nil)
+	if !errors.Is(err, ErrDownloadOverrun) {
+		t.Fatalf("expected ErrDownloadOverrun, got %v", err)
+	}
+}
+
+// zeros is an endless reader of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,188 @@
+package pod
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Download orders of new episodes.
+const (
+	OrderFeed   = "feed"   // As listed in the feed
+	OrderOldest = "oldest" // Oldest published first
+	OrderNewest = "newest" // Newest published first
+)
+
+// PodSettings are the optional settings of a podcast. Settings left
+// unset fall back to the global settings of the podcast's library, or
+// to Defaults for podcasts without a library.
+type PodSettings struct {
+	Paused           *bool      `json:"paused,omitempty"`            // Skip the podcast when updating
+	AutoApprove      *bool      `json:"auto_approve,omitempty"`      // Download new episodes without asking
+	FilenameTemplate string     `json:"filename_template,omitempty"` // Template for episode file names
+	Retention        *Retention `json:"retention,omitempty"`         // Rules which downloaded episodes to keep
+	DownloadOrder    string     `json:"download_order,omitempty"`    // Order to download new episodes in: feed, oldest or newest
+	MaxEpisodes      *int       `json:"max_episodes,omitempty"`      // Maximum number of new episodes downloaded per run, 0 for all
+	EnclosureType    string     `json:"enclosure_type,omitempty"`    // Preferred MIME type of episodes with several enclosures
+	TagFiles         *bool      `json:"tag_files,omitempty"`         // Write feed metadata into downloaded files
+	EpisodeArtwork   *bool      `json:"episode_artwork,omitempty"`   // Save episode images next to the episode files
+}
+
+// Defaults are the defaults of the settings of podcasts without a
+// library.
+var Defaults = new(PodSettings)
+
+// Validate checks the settings for errors.
+func (s *PodSettings) Validate() error {
+	switch s.DownloadOrder {
+	case "", OrderFeed, OrderOldest, OrderNewest:
+	default:
+		return fmt.Errorf("%w: download order %q, use %s, %s or %s",
+			ErrInvalidSetting, s.DownloadOrder, OrderFeed, OrderOldest, OrderNewest)
+	}
+
+	if s.MaxEpisodes != nil && *s.MaxEpisodes < 0 {
+		return fmt.Errorf("%w: negative maximum number of episodes", ErrInvalidSetting)
+	}
+
+	if err := ValidateFilenameTemplate(s.FilenameTemplate); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSetting, err)
+	}
+
+	if s.Retention != nil {
+		return s.Retention.Validate()
+	}
+
+	return nil
+}
+
+// ResolvedSettings are the settings in effect for a podcast.
+type ResolvedSettings struct {
+	Paused           bool
+	AutoApprove      bool
+	FilenameTemplate string
+	Retention        *Retention // Rules of the podcast merged with the default ones, nil if there are none
+	DownloadOrder    string
+	MaxEpisodes      int
+	EnclosureType    string
+	TagFiles         bool
+	EpisodeArtwork   bool
+}
+
+// Resolved returns the settings in effect for the podcast, filling in
+// the global defaults for unset ones.
+func (pod *Podcast) Resolved() *ResolvedSettings {
+	own, def := &pod.Settings, pod.defaults()
+
+	r := &ResolvedSettings{
+		Paused:           resolveBool(own.Paused, def.Paused),
+		AutoApprove:      resolveBool(own.AutoApprove, def.AutoApprove),
+		FilenameTemplate: pod.filenameTemplate(),
+		Retention:        mergeRetention(own.Retention, def.Retention),
+		DownloadOrder:    resolveString(own.DownloadOrder, def.DownloadOrder),
+		EnclosureType:    resolveString(own.EnclosureType, def.EnclosureType),
+		TagFiles:         resolveBool(own.TagFiles, def.TagFiles),
+		EpisodeArtwork:   resolveBool(own.EpisodeArtwork, def.EpisodeArtwork),
+	}
+
+	if r.DownloadOrder == "" {
+		r.DownloadOrder = OrderFeed
+	}
+
+	if own.MaxEpisodes != nil {
+		r.MaxEpisodes = *own.MaxEpisodes
+	} else if def.MaxEpisodes != nil {
+		r.MaxEpisodes = *def.MaxEpisodes
+	}
+
+	return r
+}
+
+func resolveBool(own, def *bool) bool {
+	if own != nil {
+		return *own
+	}
+
+	return def != nil && *def
+}
+
+func resolveString(own, def string) string {
+	if own != "" {
+		return own
+	}
+
+	return def
+}
+
+// mergeRetention overrides the default rules def with the rules set in
+// own. Pinned episodes come from own only.
+func mergeRetention(own, def *Retention) *Retention {
+	if own == nil && def == nil {
+		return nil
+	}
+
+	r := new(Retention)
+	if def != nil {
+		*r = *def
+		r.Pinned = nil
+	}
+
+	if own == nil {
+		return r
+	}
+
+	if own.KeepLast != nil {
+		r.KeepLast = own.KeepLast
+	}
+
+	r.MaxAge = resolveString(own.MaxAge, r.MaxAge)
+	r.MaxSize = resolveString(own.MaxSize, r.MaxSize)
+	r.Pinned = own.Pinned
+
+	return r
+}
+
+// SelectDownloads orders the new episodes eps by the download order of
+// the podcast and limits them to its maximum number of episodes per
+// run.
+func (pod *Podcast) SelectDownloads(eps []*Episode) []*Episode {
+	s := pod.Resolved()
+
+	sorted := make([]*Episode, len(eps))
+	copy(sorted, eps)
+
+	switch s.DownloadOrder {
+	case OrderOldest:
+		sort.SliceStable(sorted, func(i, j int) bool { return pubDate(sorted[i]).Before(pubDate(sorted[j])) })
+	case OrderNewest:
+		sort.SliceStable(sorted, func(i, j int) bool { return pubDate(sorted[i]).After(pubDate(sorted[j])) })
+	}
+
+	if s.MaxEpisodes > 0 && len(sorted) > s.MaxEpisodes {
+		sorted = sorted[:s.MaxEpisodes]
+	}
+
+	return sorted
+}
+
+// selectEnclosures picks the enclosure of every episode in eps with
+// several of them, preferring the podcast's enclosure type. Types are
+// matched case insensitively, a type without subtype like "audio"
+// matches all of its subtypes.
+func (pod *Podcast) selectEnclosures(eps []*Episode) {
+	pref := strings.ToLower(pod.Resolved().EnclosureType)
+	if pref == "" {
+		return
+	}
+
+	for _, e := range eps {
+		for _, enc := range e.Enclosures {
+			typ := strings.ToLower(enc.Enc)
+
+			if typ == pref || !strings.Contains(pref, "/") && strings.HasPrefix(typ, pref+"/") {
+				e.File = enc
+				break
+			}
+		}
+	}
+}
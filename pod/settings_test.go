@@ -0,0 +1,53 @@
+package pod
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolved(t *testing.T) {
+	yes, no, two, ten := true, false, 2, 10
+
+	defer func(d *PodSettings) { Defaults = d }(Defaults)
+	Defaults = &PodSettings{
+		AutoApprove:   &yes,
+		TagFiles:      &yes,
+		DownloadOrder: OrderOldest,
+		MaxEpisodes:   &two,
+		Retention:     &Retention{KeepLast: &ten, MaxAge: "90d", Pinned: []string{"x"}},
+	}
+
+	p := &Podcast{Name: "foocast", Settings: PodSettings{
+		TagFiles:  &no,
+		Retention: &Retention{MaxAge: "30d", Pinned: []string{"foo-1"}},
+	}}
+
+	s := p.Resolved()
+
+	if !s.AutoApprove || s.TagFiles || s.Paused || s.DownloadOrder != OrderOldest || s.MaxEpisodes != 2 {
+		t.Errorf("unexpected settings %+v", s)
+	}
+
+	if r := s.Retention; r.KeepLastCount() != 10 || r.MaxAge != "30d" || len(r.Pinned) != 1 || r.Pinned[0] != "foo-1" {
+		t.Errorf("unexpected retention %+v", r)
+	}
+
+	// A podcast keeping all episodes overrides the default rule.
+	zero := 0
+	p.Settings.Retention.KeepLast = &zero
+
+	if r := p.Resolved().Retention; r.KeepLast == nil || *r.KeepLast != 0 {
+		t.Errorf("keep last not overridden: %+v", r)
+	}
+
+	var eps []*Episode
+	for _, day := range []int{3, 1, 2} {
+		pd := podTime(time.Date(2021, 1, day, 0, 0, 0, 0, time.UTC))
+		eps = append(eps, &Episode{Title: string(rune('0' + day)), PubDate: &pd})
+	}
+
+	sel := p.SelectDownloads(eps)
+	if len(sel) != 2 || sel[0].Title != "1" || sel[1].Title != "2" {
+		t.Errorf("unexpected selection %v", sel)
+	}
+}
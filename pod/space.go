@@ -0,0 +1,75 @@
+package pod
+
+import (
+	"errors"
+	"sort"
+)
+
+// DefaultReserve is the free space in bytes left on a file system by
+// downloads unless configured otherwise.
+const DefaultReserve = 1 << 30
+
+// diskSpace returns an identifier of the file system holding path and
+// the space in bytes available on it. It is a variable so tests can
+// replace it.
+var diskSpace = statDisk
+
+// SpaceShortage is a podcast whose episodes don't fit on the file
+// system of its local store, or whose file system could not be
+// checked.
+type SpaceShortage struct {
+	Podcast *Podcast
+	Need    int64 // Bytes declared by the episodes to download
+	Free    int64 // Bytes available on the file system, minus the reserve and earlier podcasts
+	Err     error // Error checking the file system, Free is unknown then
+}
+
+// CheckSpace works out whether the episodes to download fit on the
+// file systems of the podcasts' local stores, leaving reserve bytes
+// free on each. Podcasts sharing a file system are taken in order of
+// their names until it is full. It returns the podcasts that don't
+// fit, along with those whose file system failed to be checked, the
+// others are not held up by them. Podcasts on file systems whose free
+// space cannot be determined at all are not checked.
+func CheckSpace(downloads map[*Podcast][]*Episode, reserve int64) []*SpaceShortage {
+	pods := make([]*Podcast, 0, len(downloads))
+	for p := range downloads {
+		pods = append(pods, p)
+	}
+
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	remaining := make(map[string]int64)
+
+	var short []*SpaceShortage
+
+	for _, p := range pods {
+		var need int64
+		for _, e := range downloads[p] {
+			if e.File != nil {
+				need += e.File.Size
+			}
+		}
+
+		fs, free, err := diskSpace(p.LocalStore)
+		if errors.Is(err, ErrFreeSpaceUnknown) {
+			continue
+		} else if err != nil {
+			short = append(short, &SpaceShortage{Podcast: p, Need: need, Err: err})
+			continue
+		}
+
+		if _, ok := remaining[fs]; !ok {
+			remaining[fs] = free - reserve
+		}
+
+		if need > remaining[fs] {
+			short = append(short, &SpaceShortage{Podcast: p, Need: need, Free: remaining[fs]})
+			continue
+		}
+
+		remaining[fs] -= need
+	}
+
+	return short
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package pod
+
+// statDisk reports that the free space is unknown on this platform.
+func statDisk(path string) (string, int64, error) {
+	return "", 0, ErrFreeSpaceUnknown
+}
@@ -0,0 +1,71 @@
+package pod
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckSpace(t *testing.T) {
+	free := map[string]int64{"/a": 1000, "/b": 300}
+	errBroken := errors.New("broken")
+
+	defer func(orig func(string) (string, int64, error)) { diskSpace = orig }(diskSpace)
+	diskSpace = func(path string) (string, int64, error) {
+		switch path {
+		case "/unknown":
+			return "", 0, ErrFreeSpaceUnknown
+		case "/broken":
+			return "", 0, errBroken
+		}
+
+		return path[:2], free[path[:2]], nil
+	}
+
+	epis := func(sizes ...int64) []*Episode {
+		var eps []*Episode
+		for _, s := range sizes {
+			eps = append(eps, &Episode{File: &podFile{Size: s}})
+		}
+		return eps
+	}
+
+	pods := make(map[string]*Podcast)
+	for _, name := range []string{"a1", "a2", "a3", "b1", "unknown", "broken"} {
+		dir := "/" + name[:1] + "/" + name
+		if name == "unknown" || name == "broken" {
+			dir = "/" + name
+		}
+		pods[name] = &Podcast{Name: name, LocalStore: dir}
+	}
+
+	downloads := map[*Podcast][]*Episode{
+		pods["a1"]:      epis(300, 200),
+		pods["a2"]:      epis(500),
+		pods["a3"]:      epis(100),
+		pods["b1"]:      epis(250),
+		pods["unknown"]: epis(1 << 40),
+		pods["broken"]:  epis(10),
+	}
+
+	short := CheckSpace(downloads, 100)
+
+	// a1 and a3 fit into the 900 bytes above the reserve, a2 doesn't
+	// after a1, and b1 exceeds the 200 bytes above the reserve on /b.
+	// The file system of broken fails to be checked, which holds up
+	// none of the others.
+	expected := map[string]int64{"a2": 400, "b1": 200, "broken": 0}
+
+	if len(short) != len(expected) {
+		t.Fatalf("got %d shortages, expected %d", len(short), len(expected))
+	}
+
+	for _, s := range short {
+		if free, ok := expected[s.Podcast.Name]; !ok || s.Free != free {
+			t.Errorf("%s: unexpected shortage with %d bytes free", s.Podcast.Name, s.Free)
+		}
+
+		if (s.Podcast.Name == "broken") != errors.Is(s.Err, errBroken) {
+			t.Errorf("%s: unexpected error %v", s.Podcast.Name, s.Err)
+		}
+	}
+}
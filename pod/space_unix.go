@@ -0,0 +1,31 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package pod
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// statDisk returns the device of the file system holding path and the
+// space in bytes available on it to unprivileged users.
+func statDisk(path string) (string, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", 0, ErrFreeSpaceUnknown
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return "", 0, err
+	}
+
+	return fmt.Sprint(st.Dev), int64(fs.Bavail) * int64(fs.Bsize), nil
+}
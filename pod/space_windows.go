@@ -0,0 +1,29 @@
+package pod
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// statDisk returns the volume holding path and the space in bytes
+// available on it to the current user.
+func statDisk(path string) (string, int64, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	dir, err := windows.UTF16PtrFromString(abs)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &free, &total, &totalFree); err != nil {
+		return "", 0, err
+	}
+
+	return strings.ToLower(filepath.VolumeName(abs)), int64(free), nil
+}
@@ -0,0 +1,145 @@
+package pod
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Store keeps the configuration of a Library.
+type Store interface {
+	// Load returns the stored configuration.
+	Load() (*Config, error)
+
+	// Update lets change modify the stored configuration and stores
+	// the result, unless change fails. Concurrent updates are applied
+	// one after the other.
+	Update(change func(conf *Config) error) error
+}
+
+// FileStore keeps the configuration in a file, in TOML format if its
+// name ends in .toml and JSON otherwise.
+type FileStore struct {
+	Path string // Location of the configuration file
+}
+
+// NewFileStore returns a store of the configuration file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+// DefaultStore returns the store of the configuration file selected by
+// ConfigFile and Profile.
+func DefaultStore() (*FileStore, error) {
+	cf, err := confFile()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFileStore(cf), nil
+}
+
+// Load reads the configuration file, creating it if missing. A file of
+// an older format is migrated and written back first.
+func (s *FileStore) Load() (*Config, error) {
+	buf, err := readConfFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, version, err := decodeConfigFile(s.Path, buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+
+	if version < ConfigVersion {
+		if err := s.Update(func(*Config) error { return nil }); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Path, err)
+		}
+	}
+
+	return conf, nil
+}
+
+// Update reads the configuration file, lets change modify it and
+// writes it back. The file is locked against other gopodgrab processes
+// in the meantime and replaced atomically. The previous version is kept
+// as a backup next to it. Files of an older format are migrated, and
+// kept as a backup named after their version as well.
+func (s *FileStore) Update(change func(conf *Config) error) error {
+	unlock, err := lockFile(s.Path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	buf, err := readConfFile(s.Path)
+	if err != nil {
+		return err
+	}
+
+	conf, version, err := decodeConfigFile(s.Path, buf)
+	if err != nil {
+		return err
+	}
+
+	if err := change(conf); err != nil {
+		return err
+	}
+
+	out, err := encodeConfig(s.Path, conf, buf)
+	if err != nil {
+		return err
+	}
+
+	return writeConfig(s.Path, buf, version, out)
+}
+
+// MemoryStore keeps the configuration in memory, for tests and programs
+// embedding gopodgrab. Every Load returns a copy of its own.
+type MemoryStore struct {
+	mu  sync.Mutex
+	buf []byte // Configuration in JSON format
+}
+
+// NewMemoryStore returns a store of an empty configuration.
+func NewMemoryStore() *MemoryStore {
+	return new(MemoryStore)
+}
+
+// Load returns a copy of the stored configuration.
+func (s *MemoryStore) Load() (*Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conf, _, err := decodeConfig(s.buf)
+
+	return conf, err
+}
+
+// Update lets change modify a copy of the stored configuration, which
+// replaces it afterwards.
+func (s *MemoryStore) Update(change func(conf *Config) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conf, _, err := decodeConfig(s.buf)
+	if err != nil {
+		return err
+	}
+
+	if err := change(conf); err != nil {
+		return err
+	}
+
+	conf.Version = ConfigVersion
+
+	buf, err := json.Marshal(conf)
+	if err != nil {
+		return err
+	}
+
+	s.buf = buf
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package pod
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Actions of a subscription sync.
+const (
+	SyncAdd     = "add"    // Add the listed podcast
+	SyncAdopt   = "adopt"  // Sync the managed podcast with the list from now on
+	SyncFeedURL = "feed"   // Change the feed URL of the podcast to the listed one
+	SyncPause   = "pause"  // Pause the podcast no longer listed
+	SyncRemove  = "remove" // Remove the podcast no longer listed
+	SyncKeep    = "keep"   // Leave the podcast no longer listed alone
+)
+
+// SyncChange is a change of the library by a subscription sync.
+type SyncChange struct {
+	Action     string        // One of the Sync actions
+	Podcast    *Podcast      // Podcast changed, nil when adding until applied
+	Sub        *Subscription // Listed subscription, nil for podcasts no longer listed
+	OldFeedURL string        // Feed URL before a SyncFeedURL change
+	Err        error         // Reason applying the change failed
+}
+
+// FetchOPML fetches the subscriptions from the OPML document at rawURL.
+func FetchOPML(rawURL string) ([]*Subscription, error) {
+	if err := checkURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	return ParseOPML(resp.Body)
+}
+
+// PlanSync compares the library with the subscriptions listed by the
+// OPML document at source and returns the changes syncing it. Missing
+// podcasts are added, managed ones listed are adopted. A listed feed
+// that is not managed, but matches a podcast of the source by name or
+// channel title, is taken as a changed feed URL. Podcasts of the source
+// no longer listed get the unlisted action, SyncPause, SyncRemove or
+// SyncKeep.
+func (lib *Library) PlanSync(source string, subs []*Subscription, unlisted string) ([]*SyncChange, error) {
+	switch unlisted {
+	case SyncPause, SyncRemove, SyncKeep:
+	default:
+		return nil, fmt.Errorf("unknown action %q for podcasts no longer listed, use %s, %s or %s",
+			unlisted, SyncKeep, SyncPause, SyncRemove)
+	}
+
+	var changes []*SyncChange
+	listed := make(map[*Podcast]bool)
+	var unknown []*Subscription
+
+	for _, sub := range subs {
+		p := lib.byFeed(sub.FeedURL)
+		if p == nil {
+			unknown = append(unknown, sub)
+			continue
+		}
+
+		listed[p] = true
+
+		if p.Source != source {
+			changes = append(changes, &SyncChange{Action: SyncAdopt, Podcast: p, Sub: sub})
+		}
+	}
+
+	for _, sub := range unknown {
+		if p := lib.syncedMatch(source, sub, listed); p != nil {
+			listed[p] = true
+			changes = append(changes, &SyncChange{Action: SyncFeedURL, Podcast: p, Sub: sub, OldFeedURL: p.FeedURL})
+			continue
+		}
+
+		changes = append(changes, &SyncChange{Action: SyncAdd, Sub: sub})
+	}
+
+	for _, p := range lib.Podcasts() {
+		if p.Source != source || listed[p] {
+			continue
+		}
+
+		action := unlisted
+		if action == SyncPause && p.Settings.Paused != nil && *p.Settings.Paused {
+			action = SyncKeep
+		}
+
+		changes = append(changes, &SyncChange{Action: action, Podcast: p})
+	}
+
+	return changes, nil
+}
+
+// syncedMatch returns the podcast of the source, not listed yet, that
+// matches the subscription by name or channel title.
+func (lib *Library) syncedMatch(source string, sub *Subscription, listed map[*Podcast]bool) *Podcast {
+	name := podName(sub)
+
+	for _, p := range lib.Podcasts() {
+		if p.Source != source || listed[p] {
+			continue
+		}
+
+		if p.Name == name {
+			return p
+		}
+
+		if ch, _, err := p.readFeedChannel(); err == nil && strings.EqualFold(strings.TrimSpace(ch.Title), sub.Title) {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// ApplySync applies the changes of PlanSync for the OPML document at
+// source. Podcasts are added as Import does. Changes failing record
+// their error, the error returned is about saving the library.
+func (lib *Library) ApplySync(source string, changes []*SyncChange, storageRoot string, jobs int) error {
+	var subs []*Subscription
+	var adds []*SyncChange
+	var changed []*Podcast
+	var removed []string
+
+	for _, c := range changes {
+		switch c.Action {
+		case SyncAdd:
+			subs = append(subs, c.Sub)
+			adds = append(adds, c)
+		case SyncAdopt:
+			c.Podcast.Source = source
+			changed = append(changed, c.Podcast)
+		case SyncFeedURL:
+			if err := checkURL(c.Sub.FeedURL); err != nil {
+				c.Err = err
+				continue
+			}
+
+			c.Podcast.FeedURL = c.Sub.FeedURL
+			c.Podcast.Source = source
+			changed = append(changed, c.Podcast)
+		case SyncPause:
+			yes := true
+			c.Podcast.Settings.Paused = &yes
+			changed = append(changed, c.Podcast)
+		case SyncRemove:
+			removed = append(removed, c.Podcast.Name)
+		}
+	}
+
+	for i, res := range lib.Import(subs, storageRoot, jobs) {
+		adds[i].Podcast, adds[i].Err = res.Podcast, res.Err
+
+		if res.Podcast != nil {
+			res.Podcast.Source = source
+			changed = append(changed, res.Podcast)
+		}
+	}
+
+	if err := lib.Save(changed...); err != nil {
+		return err
+	}
+
+	return lib.Remove(removed...)
+}
@@ -0,0 +1,134 @@
+package pod
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSync(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/added.xml" {
+			fmt.Fprint(w, `<rss><channel><title>Added</title><item><title>First</title><guid>added-1</guid>`+
+				`<enclosure url="https://example.com/added-1.mp3" type="audio/mpeg" length="1000"/></item></channel></rss>`)
+			return
+		}
+
+		fmt.Fprintf(w, `<opml version="2.0"><body>
+<outline text="Manual" xmlUrl="%[1]s/manual.xml"/>
+<outline text="Renamed Show" xmlUrl="%[1]s/new.xml"/>
+<outline text="Added" xmlUrl="%[1]s/added.xml"/>
+</body></opml>`, srv.URL)
+	}))
+	defer srv.Close()
+
+	source := srv.URL + "/list.opml"
+
+	lib, err := NewLibrary(NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []*Podcast{
+		{Name: "manual", FeedURL: srv.URL + "/manual.xml"},
+		{Name: "gone", FeedURL: srv.URL + "/gone.xml", Source: source},
+		{Name: "other", FeedURL: srv.URL + "/other.xml", Source: "http://example.com/other.opml"},
+		{Name: "renamed-show", FeedURL: srv.URL + "/old.xml", Source: source},
+	} {
+		if err := lib.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := lib.Save(lib.Podcasts()...); err != nil {
+		t.Fatal(err)
+	}
+
+	subs, err := FetchOPML(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := lib.PlanSync(source, subs, SyncPause)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actions []string
+	for _, c := range changes {
+		var name string
+		if c.Podcast != nil {
+			name = c.Podcast.Name
+		} else {
+			name = c.Sub.Title
+		}
+
+		actions = append(actions, c.Action+" "+name)
+	}
+
+	want := []string{"adopt manual", "feed renamed-show", "add Added", "pause gone"}
+	if !reflect.DeepEqual(actions, want) {
+		t.Fatalf("got changes %v, expected %v", actions, want)
+	}
+
+	if err := lib.ApplySync(source, changes, t.TempDir(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lib.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, check := range map[string]func(p *Podcast) bool{
+		"manual":       func(p *Podcast) bool { return p.Source == source },
+		"renamed-show": func(p *Podcast) bool { return p.FeedURL == srv.URL+"/new.xml" },
+		"added":        func(p *Podcast) bool { return p.Source == source && p.FeedURL == srv.URL+"/added.xml" },
+		"gone":         func(p *Podcast) bool { return p.Resolved().Paused },
+		"other":        func(p *Podcast) bool { return !p.Resolved().Paused },
+	} {
+		p, err := lib.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !check(p) {
+			t.Errorf("%s not synced: %+v", name, p)
+		}
+	}
+
+	// Podcasts added without fetching get their feed on the first update.
+	added, err := lib.Get("added")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fileExists(added.FeedFile()) {
+		t.Fatal("feed of added podcast fetched without jobs")
+	}
+
+	if eps, err := added.NewEpisodes(); err != nil || len(eps) != 1 {
+		t.Errorf("expected 1 new episode of the added podcast, got %v, %v", eps, err)
+	}
+
+	// Once synced, only the paused podcast is left, which stays paused.
+	changes, err = lib.PlanSync(source, subs, SyncPause)
+	if err != nil || len(changes) != 1 || changes[0].Action != SyncKeep {
+		t.Errorf("unexpected changes after syncing: %v, %v", changes, err)
+	}
+
+	changes, err = lib.PlanSync(source, subs, SyncRemove)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lib.ApplySync(source, changes, "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if lib.Has("gone") || !lib.Has("other") {
+		t.Error("podcast no longer listed not removed")
+	}
+}
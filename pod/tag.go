@@ -0,0 +1,240 @@
+package pod
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxCoverSize is the maximum size in bytes of cover art embedded
+// into episode files.
+const maxCoverSize = 10 << 20
+
+// Tags is the metadata written into downloaded episode files.
+type Tags struct {
+	Title       string    // Title of the episode
+	Podcast     string    // Title of the podcast, used as album
+	Author      string    // Author of the podcast, used as artist
+	Description string    // Description of the episode in plain text
+	Date        time.Time // Publishing date of the episode
+	Episode     int       // Episode number, used as track number
+	Season      int       // Season number, used as disc number
+	Cover       []byte    // Cover art image, JPEG or PNG
+	CoverMIME   string    // MIME type of the cover art
+}
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+// episodeTags collects the tags for episode e from the feed.
+func (pod *Podcast) episodeTags(ch *Channel, e *Episode) *Tags {
+	t := &Tags{
+		Title:       e.Title,
+		Podcast:     ch.Title,
+		Author:      ch.Author,
+		Description: plainText(e.Description),
+		Date:        pubDate(e),
+		Episode:     e.Number,
+		Season:      e.Season,
+	}
+
+	if t.Podcast == "" {
+		t.Podcast = pod.Name
+	}
+
+	if t.Author == "" {
+		t.Author = t.Podcast
+	}
+
+	return t
+}
+
+// coverArt is an image along with its MIME type.
+type coverArt struct {
+	data []byte
+	mime string
+}
+
+// episodeTagger writes tags into downloaded episodes of a podcast.
+type episodeTagger struct {
+	pod     *Podcast
+	channel *Channel
+	covers  map[string]*coverArt // Cover art by URL, nil if it couldn't be fetched
+}
+
+// newEpisodeTagger creates a tagger for the episodes of the stored feed.
+func (pod *Podcast) newEpisodeTagger() (*episodeTagger, error) {
+	ch, _, err := pod.readFeedChannel()
+	if err != nil {
+		return nil, err
+	}
+
+	return &episodeTagger{pod: pod, channel: ch, covers: make(map[string]*coverArt)}, nil
+}
+
+// tag writes the tags of episode e into the file at path. Failures
+// are logged, as the episode itself was downloaded fine.
+func (t *episodeTagger) tag(e *Episode, path string) {
+	tags := t.pod.episodeTags(t.channel, e)
+
+	cover := t.cover(string(e.Image))
+	if cover == nil {
+		cover = t.cover(t.channel.Image)
+	}
+
+	if cover != nil {
+		tags.Cover, tags.CoverMIME = cover.data, cover.mime
+	}
+
+	if err := TagFile(path, tags); err != nil {
+		log.Printf("failed to tag %s: %v", path, err)
+	}
+}
+
+// cover fetches the image at url, remembering the result.
+func (t *episodeTagger) cover(url string) *coverArt {
+	if url == "" {
+		return nil
+	}
+
+	if cover, ok := t.covers[url]; ok {
+		return cover
+	}
+
+	data, mime, err := fetchImage(url)
+	if err != nil {
+		log.Printf("failed to fetch cover art: %v", err)
+		t.covers[url] = nil
+		return nil
+	}
+
+	t.covers[url] = &coverArt{data: data, mime: mime}
+
+	return t.covers[url]
+}
+
+// plainText strips HTML markup from s and collapses its whitespace.
+func plainText(s string) string {
+	s = html.UnescapeString(htmlTags.ReplaceAllString(s, " "))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// TagFile writes the tags into the audio file at path. MP3 files get an
+// ID3v2.4 tag, MP4 files (like M4A) iTunes style metadata atoms. The
+// format is detected by content. Existing tags are kept unless they are
+// overwritten. Other formats result in ErrUnsupportedFormat.
+func TagFile(path string, t *Tags) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	head := make([]byte, 12)
+	_, err = io.ReadFull(f, head)
+	f.Close()
+
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Base(path))
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")), isMPEGAudioFrame(head):
+		return writeID3(path, t)
+	case string(head[4:8]) == "ftyp":
+		return writeMP4Tags(path, t)
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Base(path))
+}
+
+// isMPEGAudioFrame reports whether head starts with the header of an
+// MPEG audio frame, as raw MP3 files do. ADTS frames of raw AAC files
+// share the sync pattern, but use the layer bits reserved in MPEG
+// audio.
+func isMPEGAudioFrame(head []byte) bool {
+	return head[0] == 0xff && head[1]&0xe0 == 0xe0 && head[1]&0x06 != 0
+}
+
+// fetchImage downloads the image at url. Only JPEG and PNG images of
+// at most maxCoverSize bytes are accepted. It returns the image and
+// its MIME type.
+func fetchImage(url string) ([]byte, string, error) {
+	if err := checkURL(url); err != nil {
+		return nil, "", err
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	if _, err := limitedCopy(&buf, resp.Body, maxCoverSize, ErrImageTooLarge); err != nil {
+		return nil, "", err
+	}
+
+	mime := http.DetectContentType(buf.Bytes())
+	if mime != "image/jpeg" && mime != "image/png" {
+		return nil, "", fmt.Errorf("%w: %s is %s", ErrUnsupportedFormat, url, mime)
+	}
+
+	return buf.Bytes(), mime, nil
+}
+
+// spliceFile replaces the bytes from start to end of the file at path
+// with data. The result is written to a temporary file first, which
+// replaces the original once complete.
+func spliceFile(path string, start, end int64, data []byte) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, io.NewSectionReader(src, 0, start)); err != nil {
+		return err
+	}
+
+	if _, err := dst.Write(data); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, io.NewSectionReader(src, end, info.Size()-end)); err != nil {
+		return err
+	}
+
+	if err := dst.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(dst.Name(), path)
+}
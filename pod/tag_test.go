@@ -0,0 +1,191 @@
+package pod
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testTags = &Tags{
+	Title:       "Episode 7: Grüße",
+	Podcast:     "Foocast",
+	Author:      "Foo",
+	Description: "All about foo.",
+	Date:        time.Date(2021, 1, 3, 10, 0, 0, 0, time.UTC),
+	Episode:     7,
+	Season:      2,
+	Cover:       []byte("\xff\xd8\xff\xe0 not really a jpeg"),
+	CoverMIME:   "image/jpeg",
+}
+
+func TestWriteID3(t *testing.T) {
+	// An ID3v2.3 tag with a title to replace and a user text frame to
+	// keep, followed by a few bytes of "audio".
+	var frames bytes.Buffer
+	for _, f := range []struct{ id, data string }{
+		{"TIT2", "\x00Old title"},
+		{"TXXX", "\x00key\x00value"},
+	} {
+		header := make([]byte, 10)
+		copy(header, f.id)
+		binary.BigEndian.PutUint32(header[4:], uint32(len(f.data)))
+		frames.Write(header)
+		frames.WriteString(f.data)
+	}
+
+	tag := []byte("ID3\x03\x00\x00\x00\x00\x00\x00")
+	putSyncsafe(tag[6:], frames.Len())
+	audio := bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x64}, 64)
+
+	path := filepath.Join(t.TempDir(), "episode.mp3")
+	if err := ioutil.WriteFile(path, append(append(tag, frames.Bytes()...), audio...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TagFile(path, testTags); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(buf, []byte("ID3\x04\x00")) {
+		t.Fatalf("expected an ID3v2.4 header, got %q", buf[:5])
+	}
+
+	tagLen := id3HeaderLen + syncsafe(buf[6:10])
+	if !bytes.Equal(buf[tagLen:], audio) {
+		t.Error("audio data changed")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	kept, n, err := readID3Frames(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != int64(tagLen) {
+		t.Errorf("got tag length %d, expected %d", n, tagLen)
+	}
+
+	for _, want := range []string{"TXXX", "\x03Episode 7: Grüße", "\x03Foocast", "TRCK", "\x037", "TPOS", "APIC"} {
+		if !bytes.Contains(buf[:tagLen], []byte(want)) {
+			t.Errorf("tag misses %q", want)
+		}
+	}
+
+	if bytes.Contains(buf[:tagLen], []byte("Old title")) {
+		t.Error("old title was kept")
+	}
+
+	if !bytes.Contains(kept, []byte("TXXX")) {
+		t.Error("user text frame was dropped")
+	}
+}
+
+func TestWriteMP4Tags(t *testing.T) {
+	box := func(typ string, payload ...[]byte) []byte {
+		return mp4Encode(typ, bytes.Join(payload, nil))
+	}
+
+	stco := make([]byte, 12)
+	binary.BigEndian.PutUint32(stco[4:], 1)
+
+	ftyp := box("ftyp", []byte("M4A \x00\x00\x00\x00M4A mp42isom"))
+	moov := box("moov",
+		box("mvhd", make([]byte, 100)),
+		box("trak", box("mdia", box("minf", box("stbl", box("stco", stco))))))
+	mdat := box("mdat", []byte("audio samples"))
+
+	// The only chunk starts right at the samples in mdat.
+	binary.BigEndian.PutUint32(moov[len(moov)-4:], uint32(len(ftyp)+len(moov)+8))
+
+	path := filepath.Join(t.TempDir(), "episode.m4a")
+	if err := ioutil.WriteFile(path, bytes.Join([][]byte{ftyp, moov, mdat}, nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TagFile(path, testTags); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := int(binary.BigEndian.Uint32(buf[len(ftyp):]))
+	tagged, err := parseMP4Box("moov", buf[len(ftyp)+8:len(ftyp)+size])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ilst := tagged.child("udta").child("meta").child("ilst")
+
+	items := make(map[string][]byte)
+	for _, c := range ilst.children {
+		items[c.typ] = c.data
+	}
+
+	for typ, want := range map[string]string{
+		"\xa9nam": "Episode 7: Grüße",
+		"\xa9alb": "Foocast",
+		"\xa9ART": "Foo",
+		"desc":    "All about foo.",
+		"\xa9day": "2021-01-03T10:00:00Z",
+		"covr":    string(testTags.Cover),
+	} {
+		if !bytes.HasSuffix(items[typ], []byte(want)) {
+			t.Errorf("item %q: got %q, expected it to end in %q", typ, items[typ], want)
+		}
+	}
+
+	if len(items["trkn"]) != 24 || items["trkn"][19] != 7 {
+		t.Errorf("unexpected track number item %v", items["trkn"])
+	}
+
+	// The chunk offset still points to the samples.
+	stbl := tagged.child("trak").child("mdia").child("minf").child("stbl")
+	off := binary.BigEndian.Uint32(stbl.child("stco").data[8:])
+
+	if !bytes.HasPrefix(buf[off:], []byte("audio samples")) {
+		t.Errorf("chunk offset %d doesn't point to the samples", off)
+	}
+}
+
+func TestTagFileFormats(t *testing.T) {
+	tests := map[string]struct {
+		head      []byte
+		supported bool
+	}{
+		"MPEG-1 layer III": {head: []byte{0xff, 0xfb, 0x90, 0x64}, supported: true},
+		"MPEG-2 layer III": {head: []byte{0xff, 0xf3, 0x90, 0x64}, supported: true},
+		"ADTS MPEG-4 AAC":  {head: []byte{0xff, 0xf1, 0x50, 0x80}},
+		"ADTS MPEG-2 AAC":  {head: []byte{0xff, 0xf9, 0x50, 0x80}},
+		"Ogg":              {head: []byte("OggS")},
+	}
+
+	for name, test := range tests {
+		path := filepath.Join(t.TempDir(), "episode")
+		if err := ioutil.WriteFile(path, bytes.Repeat(test.head, 64), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err := TagFile(path, &Tags{Title: "Episode"})
+		if test.supported != (err == nil) || !test.supported && !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("%s: unexpected result %v", name, err)
+		}
+	}
+}
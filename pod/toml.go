@@ -0,0 +1,315 @@
+package pod
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// isTOML reports whether the configuration file at path is in TOML
+// rather than JSON format, judged by its extension.
+func isTOML(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".toml")
+}
+
+// tomlToJSON converts the TOML document buf to JSON.
+func tomlToJSON(buf []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(buf)) == 0 {
+		return nil, nil
+	}
+
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(doc)
+}
+
+// jsonToTOML converts the JSON document buf to TOML. Comments of the
+// TOML document old are carried over to the same keys and tables, see
+// keepComments.
+func jsonToTOML(buf, old []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+
+	// Nested tables get deep, their headers suffice to tell them apart.
+	enc := toml.NewEncoder(&out)
+	enc.Indent = ""
+
+	if err := enc.Encode(tomlValue(doc)); err != nil {
+		return nil, err
+	}
+
+	return keepComments(out.Bytes(), old), nil
+}
+
+// tomlValue prepares the decoded JSON value v for encoding as TOML.
+// Numbers become integers where possible and nulls are dropped, as
+// TOML has no null.
+func tomlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if e == nil {
+				delete(v, k)
+				continue
+			}
+
+			v[k] = tomlValue(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = tomlValue(e)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := v.Float64()
+		return f
+	}
+
+	return v
+}
+
+// keepComments inserts the comments of the TOML document old into the
+// TOML document doc. A block of comment lines is attached to the table
+// header or key following it, even across blank lines, comments in
+// front of the first one to the start of the document and those after
+// the last one to its end. Comments trailing a header or key/value line
+// are kept at the end of the same line.
+func keepComments(doc, old []byte) []byte {
+	comments := make(map[string][]string)
+	inline := make(map[string]string)
+
+	var head, block []string
+	var table []string
+	first := true
+
+	scan := bufio.NewScanner(bytes.NewReader(old))
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+
+		switch {
+		case strings.HasPrefix(line, "#"):
+			block = append(block, line)
+			continue
+		case line == "":
+			if len(block) > 0 && block[len(block)-1] != "" {
+				block = append(block, "")
+			}
+			continue
+		}
+
+		code, comment := splitTOMLComment(line)
+
+		path, header := tomlLinePath(code, table)
+		if header {
+			table = path
+		}
+
+		if path == nil {
+			block = nil
+			continue
+		}
+
+		key := strings.Join(path, "\x00")
+
+		if first {
+			head = trimBlank(block)
+			block = nil
+			first = false
+		}
+
+		if len(block) > 0 {
+			comments[key] = block
+		}
+
+		if comment != "" {
+			inline[key] = comment
+		}
+
+		block = nil
+	}
+
+	tail := trimBlank(block)
+
+	if len(head)+len(tail)+len(comments)+len(inline) == 0 {
+		return doc
+	}
+
+	var out bytes.Buffer
+
+	for _, c := range head {
+		out.WriteString(c + "\n")
+	}
+
+	if len(head) > 0 {
+		out.WriteString("\n")
+	}
+
+	table = nil
+
+	scan = bufio.NewScanner(bytes.NewReader(doc))
+	for scan.Scan() {
+		line := scan.Text()
+		trimmed := strings.TrimSpace(line)
+
+		path, header := tomlLinePath(trimmed, table)
+		if header {
+			table = path
+		}
+
+		if path != nil {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			key := strings.Join(path, "\x00")
+
+			for _, c := range comments[key] {
+				if c == "" {
+					out.WriteString("\n")
+					continue
+				}
+
+				out.WriteString(indent + c + "\n")
+			}
+
+			if c := inline[key]; c != "" {
+				line += " " + c
+			}
+		}
+
+		out.WriteString(line + "\n")
+	}
+
+	if len(tail) > 0 {
+		out.WriteString("\n")
+	}
+
+	for _, c := range tail {
+		out.WriteString(c + "\n")
+	}
+
+	return out.Bytes()
+}
+
+// trimBlank drops the trailing blank lines of a comment block.
+func trimBlank(block []string) []string {
+	for len(block) > 0 && block[len(block)-1] == "" {
+		block = block[:len(block)-1]
+	}
+
+	return block
+}
+
+// splitTOMLComment splits a TOML line into its code and a trailing
+// comment, if any. A # inside of a string does not start a comment.
+func splitTOMLComment(line string) (string, string) {
+	quote := rune(0)
+	escaped := false
+
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return strings.TrimSpace(line[:i]), line[i:]
+		}
+	}
+
+	return line, ""
+}
+
+// tomlLinePath returns the full key path of the TOML line, a table
+// header or a key/value pair in table. It reports whether the line is
+// a table header. Other lines, like continued arrays, have no path.
+func tomlLinePath(line string, table []string) ([]string, bool) {
+	if strings.HasPrefix(line, "[") {
+		header := strings.TrimSpace(strings.Trim(line, "[]"))
+		return parseTOMLKey(header), true
+	}
+
+	key, ok := splitTOMLKey(line)
+	if !ok {
+		return nil, false
+	}
+
+	path := append(append([]string{}, table...), parseTOMLKey(key)...)
+
+	return path, false
+}
+
+// splitTOMLKey returns the key of a key/value line.
+func splitTOMLKey(line string) (string, bool) {
+	quote := rune(0)
+
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '=':
+			return strings.TrimSpace(line[:i]), i > 0
+		case r == '[' || r == '{' || r == ']':
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+// parseTOMLKey splits a dotted TOML key into its parts, unquoting them.
+func parseTOMLKey(key string) []string {
+	var parts []string
+	var part strings.Builder
+	quote := rune(0)
+	escaped := false
+
+	for _, r := range key {
+		switch {
+		case escaped:
+			part.WriteRune(r)
+			escaped = false
+		case quote == '"' && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				part.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '.':
+			parts = append(parts, strings.TrimSpace(part.String()))
+			part.Reset()
+		case r != ' ' && r != '\t':
+			part.WriteRune(r)
+		}
+	}
+
+	return append(parts, strings.TrimSpace(part.String()))
+}
@@ -0,0 +1,182 @@
+package pod
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertConfig(t *testing.T) {
+	dir := t.TempDir()
+	ConfigFile = filepath.Join(dir, "gopodgrab.toml")
+	t.Cleanup(func() { ConfigFile = "" })
+
+	in := `# My podcasts
+
+version = 3
+
+[settings]
+  # Keep some room for the phone backup.
+  reserve = "5GiB"
+
+# Weekly, but the feed lists old episodes again.
+[podcasts."foo.cast"]
+  feed_url = "http://example.com/feed.xml"
+  name = "foo.cast"
+  local_store = "/tmp/foocast"
+  [podcasts."foo.cast".settings]
+    # Only the newest ones.
+    max_episodes = 2
+    [podcasts."foo.cast".settings.retention]
+      keep_last = 5
+      pinned = ["foo-1"]
+`
+	if err := ioutil.WriteFile(ConfigFile, []byte(in), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lib, err := OpenLibrary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := lib.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := want.Podcasts["foo.cast"]; p == nil || *p.Settings.MaxEpisodes != 2 || p.Settings.Retention.Pinned[0] != "foo-1" {
+		t.Fatalf("unexpected podcast %+v", p)
+	}
+
+	// Round trip through JSON and back to TOML.
+	js := filepath.Join(dir, "gopodgrab.json")
+	if err := lib.ConvertConfig(js, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if lib, err = NewLibrary(NewFileStore(js)); err != nil {
+		t.Fatal(err)
+	}
+
+	tml := filepath.Join(dir, "copy.toml")
+	if err := lib.ConvertConfig(tml, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if fileExists(js) || !fileExists(js+".bak") {
+		t.Error("converted configuration file not replaced by the backup")
+	}
+
+	got, err := NewFileStore(tml).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, expected %+v", got, want)
+	}
+
+	// Changes keep the comments of the TOML file.
+	if lib, err = OpenLibrary(); err != nil {
+		t.Fatal(err)
+	}
+
+	bar := &Podcast{Name: "barcast"}
+	if err := lib.Add(bar); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bar.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadFile(ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := string(buf)
+	if !strings.HasPrefix(out, "# My podcasts\n") {
+		t.Errorf("header comment lost:\n%s", out)
+	}
+
+	for _, c := range []string{
+		"# Keep some room for the phone backup.\nreserve",
+		"# Weekly, but the feed lists old episodes again.\n[podcasts.\"foo.cast\"]",
+		"# Only the newest ones.\nmax_episodes",
+		"[podcasts.barcast]",
+	} {
+		if !strings.Contains(out, c) {
+			t.Errorf("missing %q in:\n%s", c, out)
+		}
+	}
+}
+
+func TestSetConfigKeepsComments(t *testing.T) {
+	ConfigFile = filepath.Join(t.TempDir(), "gopodgrab.toml")
+	t.Cleanup(func() { ConfigFile = "" })
+
+	in := `version = 3 # do not touch
+
+# Shared by all podcasts.
+
+[settings]
+  reserve = "5GB" # phone backup
+  download_order = "oldest"
+
+[podcasts.foocast]
+  feed_url = "http://example.com/feed.xml#main" # mirror, the main feed is flaky
+  name = "foocast"
+  local_store = "/tmp/foocast"
+  [podcasts.foocast.settings] # overrides
+    max_episodes = 3 # flaky feed
+
+# The end.
+`
+	if err := ioutil.WriteFile(ConfigFile, []byte(in), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lib, err := OpenLibrary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lib.SetConfig("settings.download_order", "newest"); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ioutil.ReadFile(ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := string(buf)
+
+	for _, c := range []string{
+		"version = 3 # do not touch\n",
+		"# Shared by all podcasts.\n\n[settings]\n",
+		"reserve = \"5GB\" # phone backup\n",
+		"download_order = \"newest\"\n",
+		"feed_url = \"http://example.com/feed.xml#main\" # mirror, the main feed is flaky\n",
+		"[podcasts.foocast.settings] # overrides\n",
+		"max_episodes = 3 # flaky feed\n",
+		"\n# The end.\n",
+	} {
+		if !strings.Contains(out, c) {
+			t.Errorf("missing %q in:\n%s", c, out)
+		}
+	}
+
+	conf, err := lib.store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := conf.Podcasts["foocast"]; p == nil || p.FeedURL != "http://example.com/feed.xml#main" || *p.Settings.MaxEpisodes != 3 {
+		t.Errorf("unexpected podcast after setting a key: %+v", p)
+	}
+}
@@ -0,0 +1,125 @@
+package pod
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChangeKind tells how a downloaded episode changed upstream.
+type ChangeKind int
+
+const (
+	// Vanished episodes were removed from the feed by the publisher.
+	Vanished ChangeKind = iota
+	// Modified episodes point to a different enclosure URL or length
+	// than the one downloaded.
+	Modified
+)
+
+func (k ChangeKind) String() string {
+	if k == Vanished {
+		return "removed from feed"
+	}
+
+	return "enclosure changed"
+}
+
+// UpstreamChange is a downloaded episode that was removed from the feed
+// or changed by the publisher after it was downloaded.
+type UpstreamChange struct {
+	Kind    ChangeKind
+	Title   string   // Title of the episode as downloaded
+	File    string   // Name of the episode file in the local store
+	Episode *Episode // The episode as in the current feed, nil if vanished
+	Changes []string // Descriptions of the changed fields of modified episodes
+	New     bool     // Whether the change was noticed for the first time
+
+	key string
+}
+
+// UpstreamChanges compares the downloaded episodes against the stored
+// feed. It returns the episodes which vanished from the feed or whose
+// enclosure URL or length changed since they were downloaded. Changes
+// are recorded in the store index and only reported as New the first
+// time they are noticed. Vanished episodes are reported only once.
+func (pod *Podcast) UpstreamChanges() ([]*UpstreamChange, error) {
+	feedEpis, idx, _, err := pod.scanStore()
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*Episode, len(feedEpis))
+	for _, e := range feedEpis {
+		if e.File != nil {
+			byKey[episodeKey(e)] = e
+		}
+	}
+
+	var changes []*UpstreamChange
+	dirty := false
+	now := time.Now()
+
+	for key, se := range idx.Episodes {
+		if !se.Pruned.IsZero() {
+			continue
+		}
+
+		e, ok := byKey[key]
+		if !ok {
+			if se.Vanished.IsZero() {
+				se.Vanished = now
+				dirty = true
+				changes = append(changes, &UpstreamChange{Kind: Vanished, Title: se.Title, File: se.File, New: true, key: key})
+			}
+
+			continue
+		}
+
+		if !se.Vanished.IsZero() {
+			se.Vanished = time.Time{}
+			dirty = true
+		}
+
+		var diffs []string
+		if e.File.URL != se.URL {
+			diffs = append(diffs, "enclosure: "+se.URL+" -> "+e.File.URL)
+		}
+
+		if se.Size > 0 && e.File.Size > 0 && e.File.Size != se.Size {
+			diffs = append(diffs, fmt.Sprintf("length: %d -> %d", se.Size, e.File.Size))
+		}
+
+		if len(diffs) == 0 {
+			if !se.Changed.IsZero() {
+				se.Changed = time.Time{}
+				dirty = true
+			}
+
+			continue
+		}
+
+		isNew := se.Changed.IsZero()
+		if isNew {
+			se.Changed = now
+			dirty = true
+		}
+
+		changes = append(changes, &UpstreamChange{
+			Kind:    Modified,
+			Title:   se.Title,
+			File:    se.File,
+			Episode: e,
+			Changes: diffs,
+			New:     isNew,
+			key:     key,
+		})
+	}
+
+	if dirty {
+		if err := pod.writeIndex(idx); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,119 @@
+package pod
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// upstreamFeed is a feed of episode foo-3 and foo-1, the latter at url
+// and of the given length.
+const upstreamFeed = `<rss><channel><title>Foocast</title>
+<item><title>Bonus</title><guid>foo-3</guid><enclosure url="https://example.com/foo-3.mp3" type="audio/mpeg" length="3000"/></item>
+<item><title>Bonus</title><guid>foo-1</guid><enclosure url="%s" type="audio/mpeg" length="%d"/></item>
+</channel></rss>`
+
+// upstreamSummary describes the changes as sorted "kind title new"
+// strings.
+func upstreamSummary(changes []*UpstreamChange) []string {
+	var res []string
+	for _, c := range changes {
+		res = append(res, fmt.Sprintf("%s %s %t", c.Kind, c.Title, c.New))
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func TestUpstreamChanges(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(make([]byte, 1000))
+	}))
+	defer srv.Close()
+
+	p := fixtureStore(t, "feed.xml", map[string]int{
+		"Bonus.mp3":  1000,
+		"Second.mp3": 2000,
+	})
+
+	changes, err := p.UpstreamChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 0 {
+		t.Fatalf("unexpected changes of an unchanged feed: %v", upstreamSummary(changes))
+	}
+
+	// Second vanishes and the first Bonus moves to another URL.
+	if err := p.writeFeedFile(strings.NewReader(fmt.Sprintf(upstreamFeed, srv.URL+"/foo-1.mp3", 1000))); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name     string
+		expected []string
+	}{
+		{"First noticed", []string{"enclosure changed Bonus true", "removed from feed Second true"}},
+		{"Noticed again", []string{"enclosure changed Bonus false"}},
+	}
+
+	for _, step := range steps {
+		changes, err = p.UpstreamChanges()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res := upstreamSummary(changes); !equalStrings(res, step.expected) {
+			t.Errorf("%s: got %v, but expected %v", step.name, res, step.expected)
+		}
+	}
+
+	idx, err := p.readIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idx.Episodes["foo-2"].Vanished.IsZero() || idx.Episodes["foo-1"].Changed.IsZero() {
+		t.Errorf("changes not recorded in the store index")
+	}
+
+	if len(changes) != 1 || len(changes[0].Changes) != 1 || !strings.HasPrefix(changes[0].Changes[0], "enclosure:") {
+		t.Fatalf("expected a changed enclosure, got %+v", changes)
+	}
+
+	// Fetching the changed episode again clears the flag.
+	if err := p.DownloadEpisodes([]*Episode{changes[0].Episode}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err = p.UpstreamChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 0 {
+		t.Errorf("changes left after fetching again: %v", upstreamSummary(changes))
+	}
+
+	if idx, err = p.readIndex(); err != nil || !idx.Episodes["foo-1"].Changed.IsZero() {
+		t.Errorf("changed flag not cleared: %v", err)
+	}
+
+	// The publisher changes the length only.
+	if err := p.writeFeedFile(strings.NewReader(fmt.Sprintf(upstreamFeed, srv.URL+"/foo-1.mp3", 1200))); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err = p.UpstreamChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 || !changes[0].New || len(changes[0].Changes) != 1 || changes[0].Changes[0] != "length: 1000 -> 1200" {
+		t.Errorf("expected a changed length, got %v", upstreamSummary(changes))
+	}
+}